@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// buildConfig is a single target platform configuration.
+// Zero value means the host platform.
+type buildConfig struct {
+	goos   string
+	goarch string
+}
+
+func (config buildConfig) String() string {
+	if config.goos == "" {
+		return "host"
+	}
+	return config.goos + "/" + config.goarch
+}
+
+// env returns the environment variables that select the config platform.
+func (config buildConfig) env() []string {
+	if config.goos == "" {
+		return nil
+	}
+	return []string{"GOOS=" + config.goos, "GOARCH=" + config.goarch}
+}
+
+// parseBuildMatrix parses a comma-separated list of GOOS/GOARCH pairs.
+func parseBuildMatrix(s string) ([]buildConfig, error) {
+	var configs []buildConfig
+	seen := make(map[buildConfig]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		goos, goarch, ok := strings.Cut(part, "/")
+		if !ok || goos == "" || goarch == "" {
+			return nil, fmt.Errorf("%q: expected GOOS/GOARCH pair", part)
+		}
+		config := buildConfig{goos: goos, goarch: goarch}
+		if !seen[config] {
+			seen[config] = true
+			configs = append(configs, config)
+		}
+	}
+	return configs, nil
+}
+
+// withBuildTags returns flags extended with -tags, if they were specified.
+func (ctxt *context) withBuildTags(flags []string) []string {
+	if ctxt.flags.tags == "" {
+		return flags
+	}
+	result := make([]string, 0, len(flags)+1)
+	result = append(result, flags...)
+	return append(result, "-tags="+ctxt.flags.tags)
+}
+
+// isExcludedByBuildConstraints reports whether pkg has no files
+// for the current build configuration.
+func isExcludedByBuildConstraints(pkg *packages.Package) bool {
+	if len(pkg.GoFiles) != 0 || len(pkg.Errors) == 0 {
+		return false
+	}
+	for _, err := range pkg.Errors {
+		if !strings.Contains(err.Msg, "build constraints exclude all Go files") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseBuildMatrix(t *testing.T) {
+	configs, err := parseBuildMatrix("linux/amd64, windows/amd64,js/wasm,linux/amd64")
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := fmt.Sprint(configs)
+	want := "[linux/amd64 windows/amd64 js/wasm]"
+	if have != want {
+		t.Errorf("parse build matrix: have %s, want %s", have, want)
+	}
+
+	for _, s := range []string{"linux", "linux/", "/amd64"} {
+		if _, err := parseBuildMatrix(s); err == nil {
+			t.Errorf("parse build matrix %q: expected an error", s)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheVersion should be incremented every time the cache
+// entries format or the meaning of their contents changes.
+const cacheVersion = 2
+
+// Special -cache flag values; any other value is a cache directory.
+const (
+	cacheOn  = "on"
+	cacheOff = "off"
+)
+
+// Every analyzed path is cached separately.
+// The cache key includes everything that can affect the analysis results:
+// the path files contents, the dependencies files metadata, build settings,
+// Go version and the enabled checkers set.
+//
+// When the key matches, the path candidates are taken from the cache entry
+// and the packages are not loaded (and typechecked) at all.
+
+// cacheEntry is a cached analysis result of a single target path.
+type cacheEntry struct {
+	// Files are the analyzed files, see context.seenFiles.
+	Files []string
+
+	Candidates []cachedCandidate
+}
+
+type cachedCandidate struct {
+	Filename  string
+	Line      int
+	Column    int
+	VariantID uint16
+	Pool      string
+	Quiet     bool
+	Mismatch  bool
+}
+
+// cacheEnabled reports whether the analysis results can be cached.
+// The -stdin-filename and per-candidate hooks make the results non-reproducible.
+func (ctxt *context) cacheEnabled() bool {
+	return ctxt.flags.cache != "" && ctxt.flags.cache != cacheOff &&
+		ctxt.stdinFile == "" &&
+		ctxt.onlyFile == "" &&
+		ctxt.markHook == nil
+}
+
+// cacheDir returns a directory where the cache entries are stored.
+func (ctxt *context) cacheDir() (string, error) {
+	return cacheDirFor(ctxt.flags.cache)
+}
+
+func cacheDirFor(flagValue string) (string, error) {
+	if flagValue != cacheOn {
+		return flagValue, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-consistent"), nil
+}
+
+// collectCachedPathCandidates is a collectPathCandidates version that
+// consults the cache first and stores the results in it on a miss.
+func (ctxt *context) collectCachedPathCandidates(p targetPath, config buildConfig) error {
+	if !ctxt.cacheEnabled() {
+		return ctxt.collectPathCandidates(p, config)
+	}
+	dir, err := ctxt.cacheDir()
+	if err != nil {
+		ctxt.infoPrintf("cache: %v", err)
+		return ctxt.collectPathCandidates(p, config)
+	}
+	key, pkgs, err := ctxt.cacheKey(p, config)
+	if err != nil {
+		ctxt.infoPrintf("cache: %s: %v", p.path, err)
+		return ctxt.collectPathCandidates(p, config)
+	}
+	filename := filepath.Join(dir, key[:2], key)
+
+	if entry, err := readCacheEntry(filename); err == nil {
+		if ctxt.applyCacheEntry(entry) {
+			ctxt.cacheStats.hits++
+			ctxt.infoPrintf("cache: %s: hit", p.path)
+			if ctxt.onLoad != nil {
+				ctxt.onLoad(pkgs)
+			}
+			return nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		ctxt.infoPrintf("cache: %v", err)
+	}
+	ctxt.cacheStats.misses++
+
+	// Collect the candidates into a separate list,
+	// so they can be stored in the cache entry.
+	var entry cacheEntry
+	onSeen := ctxt.onSeen
+	ctxt.onSeen = func(key string) {
+		entry.Files = append(entry.Files, key)
+		if onSeen != nil {
+			onSeen(key)
+		}
+	}
+	allCandidates := ctxt.candidates
+	ctxt.candidates = candidateList{spillThreshold: allCandidates.spillThreshold}
+	defer func() {
+		ctxt.onSeen = onSeen
+		ctxt.candidates.Reset()
+		ctxt.candidates = allCandidates
+	}()
+
+	seenSkips := ctxt.seenSkips
+	if err := ctxt.collectPathCandidates(p, config); err != nil {
+		return err
+	}
+	// The results depend on the other paths analysis if some
+	// files were skipped, so they can't be reused separately.
+	complete := ctxt.seenSkips == seenSkips
+	err = ctxt.candidates.Visit(func(c candidate) {
+		pos := ctxt.locs.Get(c.loc)
+		entry.Candidates = append(entry.Candidates, cachedCandidate{
+			Filename:  pos.Filename,
+			Line:      pos.Line,
+			Column:    pos.Column,
+			VariantID: c.variantID,
+			Pool:      ctxt.pools[c.pool],
+			Quiet:     c.quiet,
+			Mismatch:  c.mismatch,
+		})
+		allCandidates.Append(c)
+	})
+	if err != nil {
+		return err
+	}
+	if !complete {
+		ctxt.infoPrintf("cache: %s: not cached, some files were analyzed as a part of the other paths", p.path)
+		return nil
+	}
+	if err := writeCacheEntry(filename, &entry); err != nil {
+		ctxt.infoPrintf("cache: %v", err)
+	}
+	return nil
+}
+
+// applyCacheEntry adds the cached candidates as if they were collected.
+// Returns false if the entry can't be used, because some of
+// its files were already analyzed as a part of the other path.
+func (ctxt *context) applyCacheEntry(entry *cacheEntry) bool {
+	for _, key := range entry.Files {
+		if _, ok := ctxt.seenFiles[key]; ok {
+			return false
+		}
+	}
+	for _, key := range entry.Files {
+		ctxt.seenFiles[key] = struct{}{}
+		if ctxt.onSeen != nil {
+			ctxt.onSeen(key)
+		}
+	}
+
+	variants := ctxt.variantsByID()
+	for _, c := range entry.Candidates {
+		ctxt.addCandidate(c.Filename, c.Line, c.Column, variants[c.VariantID], ctxt.internPool(c.Pool), c.Quiet, c.Mismatch)
+	}
+	return true
+}
+
+// cacheKey returns a cache key for the p path analysis results.
+//
+// To compute it, the path packages are loaded without the syntax and types,
+// the loaded packages are returned along with the key.
+func (ctxt *context) cacheKey(p targetPath, config buildConfig) (string, []*packages.Package, error) {
+	conf := &packages.Config{
+		Mode:       packages.NeedName | packages.NeedFiles | packages.NeedImports | packages.NeedDeps,
+		Tests:      ctxt.flags.tests != testsExclude,
+		Dir:        p.dir,
+		BuildFlags: ctxt.withBuildTags(p.buildFlags),
+		Overlay:    ctxt.overlay,
+	}
+	env := append(config.env(), p.env...)
+	if len(env) != 0 {
+		conf.Env = append(os.Environ(), env...)
+	}
+	pkgs, err := packages.Load(conf, p.path)
+	if err != nil {
+		return "", nil, err
+	}
+	var loadErr error
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if len(pkg.Errors) != 0 && loadErr == nil {
+			loadErr = pkg.Errors[0]
+		}
+	})
+	if loadErr != nil {
+		// Let the non-cached path report the errors.
+		return "", nil, loadErr
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "version %d %s\n", cacheVersion, runtime.Version())
+	fmt.Fprintf(h, "path %q %q %q %q\n", p.path, p.dir, env, conf.BuildFlags)
+	// Skip patterns are matched against the paths relative to the working directory.
+	fmt.Fprintf(h, "workdir %q\n", ctxt.workDir)
+	f := ctxt.flags
+	fmt.Fprintf(h, "flags %v %v %q %q %q %q %q %q %q\n",
+		f.pedantic, f.noTypes, f.tests, f.scope, f.includeGenerated, f.generatedPattern,
+		f.skipFiles, f.skipDirs, f.modfile)
+	for _, c := range ctxt.checkers {
+		op := c.Operation()
+		fmt.Fprintf(h, "op %q", op.name)
+		for _, v := range op.variants {
+			fmt.Fprintf(h, " %d:%q", v.id, v.warning)
+		}
+		fmt.Fprintln(h)
+	}
+
+	roots := make(map[*packages.Package]bool, len(pkgs))
+	for _, pkg := range pkgs {
+		roots[pkg] = true
+	}
+	var files []string
+	var depFiles []string
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if roots[pkg] {
+			files = append(files, pkg.GoFiles...)
+		} else {
+			depFiles = append(depFiles, pkg.GoFiles...)
+		}
+	})
+	sort.Strings(files)
+	sort.Strings(depFiles)
+
+	// The analyzed files are hashed by their contents,
+	// but the dependencies are only described by their metadata,
+	// otherwise, the std packages hashing would take too much time.
+	for _, filename := range files {
+		if ctxt.staged != nil && ctxt.staged.untracked[filename] {
+			fmt.Fprintf(h, "untracked %s\n", filename)
+		}
+		if data, ok := ctxt.overlay[filename]; ok {
+			fmt.Fprintf(h, "file %s\n", filename)
+			h.Write(data)
+			continue
+		}
+		if err := hashFile(h, filename); err != nil {
+			return "", nil, err
+		}
+	}
+	for _, filename := range depFiles {
+		if data, ok := ctxt.overlay[filename]; ok {
+			fmt.Fprintf(h, "dep %s %x\n", filename, sha256.Sum256(data))
+			continue
+		}
+		info, err := os.Stat(filename)
+		if err != nil {
+			return "", nil, err
+		}
+		fmt.Fprintf(h, "dep %s %d %d\n", filename, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), pkgs, nil
+}
+
+func hashFile(w io.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Fprintf(w, "file %s\n", filename)
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func readCacheEntry(filename string) (*cacheEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entry cacheEntry
+	if err := gob.NewDecoder(f).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", filename, err)
+	}
+	return &entry, nil
+}
+
+func writeCacheEntry(filename string, entry *cacheEntry) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	// Write into a temporary file first, so the concurrent
+	// runs never observe partially written entries.
+	f, err := os.CreateTemp(filepath.Dir(filename), "tmp-*")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(entry)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// runCache implements the "cache" subcommand.
+//
+// Usage: go-consistent cache clean [-cache=dir]
+func runCache(args []string) error {
+	if len(args) == 0 || args[0] != "clean" {
+		return errors.New("usage: go-consistent cache clean [-cache=dir]")
+	}
+	fs := flag.NewFlagSet("cache clean", flag.ExitOnError)
+	cache := fs.String("cache", cacheOn, `cache directory; "on" means the default user cache directory`)
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *cache == cacheOff || *cache == "" {
+		return nil
+	}
+	dir, err := cacheDirFor(*cache)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(filepath.Base(dir), "go-consistent") && !isCacheDir(dir) {
+		return fmt.Errorf("%s doesn't look like a go-consistent cache directory", dir)
+	}
+	return os.RemoveAll(dir)
+}
+
+// isCacheDir reports whether dir contains only the cache entries.
+func isCacheDir(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return errors.Is(err, os.ErrNotExist)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || len(e.Name()) != 2 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestCache(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	run := func() (*context, []string) {
+		var ctxt context
+		ctxt.flags.cache = cacheDir
+		ctxt.flags.tests = testsSeparate
+		analyzeTestdata(t, &ctxt, "./testdata/tests_separate")
+		var warnings []string
+		err := visitWarnings(&ctxt, func(w warning) {
+			warnings = append(warnings, w.pos.String()+": "+w.suggested.warning)
+		})
+		if err != nil {
+			t.Fatalf("visit warnings: %v", err)
+		}
+		return &ctxt, warnings
+	}
+
+	ctxt, want := run()
+	if ctxt.cacheStats.hits != 0 || ctxt.cacheStats.misses != 1 {
+		t.Fatalf("first run: have %+v cache stats, want 1 miss", ctxt.cacheStats)
+	}
+	ctxt, have := run()
+	if ctxt.cacheStats.hits != 1 || ctxt.cacheStats.misses != 0 {
+		t.Fatalf("second run: have %+v cache stats, want 1 hit", ctxt.cacheStats)
+	}
+	if len(want) != 2 {
+		t.Errorf("have %d warnings, want 2", len(want))
+	}
+	if len(have) != len(want) {
+		t.Fatalf("cached warnings mismatch:\nhave: %q\nwant: %q", have, want)
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("cached warning mismatch:\nhave: %s\nwant: %s", have[i], want[i])
+		}
+	}
+}
+
+func TestCacheOverlappingTargets(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	run := func(cache string, targets ...string) []string {
+		t.Helper()
+		var ctxt context
+		fs := flag.NewFlagSet("go-consistent", flag.ContinueOnError)
+		if err := ctxt.parseArgs(fs, append([]string{"-cache=" + cache}, targets...)); err != nil {
+			t.Fatalf("parse args: %v", err)
+		}
+		steps := []func() error{
+			ctxt.resolveTargets,
+			ctxt.initCheckers,
+			ctxt.collectAllCandidates,
+			ctxt.assignSuggestions,
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				t.Fatalf("%q: %v", targets, err)
+			}
+		}
+		var warnings []string
+		err := visitWarnings(&ctxt, func(w warning) {
+			warnings = append(warnings, w.pos.String()+": "+w.suggested.warning)
+		})
+		if err != nil {
+			t.Fatalf("visit warnings: %v", err)
+		}
+		return warnings
+	}
+
+	// The package target is analyzed without the file
+	// that was already analyzed as a part of the file target.
+	run(cacheDir, "./testdata/tests_separate/alloc.go", "./testdata/tests_separate")
+
+	want := run(cacheOff, "./testdata/tests_separate")
+	have := run(cacheDir, "./testdata/tests_separate")
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("cached warnings mismatch:\nhave: %q\nwant: %q", have, want)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+type candidate struct {
+	loc location
+
+	variantID uint16
+
+	// pool is an ID of the voting pool this candidate belongs to.
+	pool uint16
+
+	// quiet candidates are counted, but never reported.
+	quiet bool
+
+	// mismatch candidates are not counted, see context.markMismatch.
+	mismatch bool
+}
+
+// candidateSize is a size of the encoded candidate inside the spill file.
+const candidateSize = 8 + 2 + 2 + 1
+
+// candidateList is an append-only candidates storage.
+//
+// When spillThreshold is not zero, at most spillThreshold candidates
+// are kept in memory; when this limit is reached, they're flushed
+// into a temporary file. This makes the memory consumption flat
+// regardless of the number of candidates collected.
+type candidateList struct {
+	spillThreshold int
+
+	buf []candidate
+
+	spill   *os.File
+	w       *bufio.Writer
+	spilled int
+
+	// err is the first spilling error.
+	// It's reported by the Visit method.
+	err error
+}
+
+// Len returns the total number of stored candidates.
+func (l *candidateList) Len() int { return l.spilled + len(l.buf) }
+
+// Append adds c to the list.
+func (l *candidateList) Append(c candidate) {
+	l.buf = append(l.buf, c)
+	if l.spillThreshold != 0 && len(l.buf) >= l.spillThreshold {
+		l.flush()
+	}
+}
+
+// Visit calls visit for every candidate, in the order they were appended.
+func (l *candidateList) Visit(visit func(c candidate)) error {
+	if l.err != nil {
+		return l.err
+	}
+
+	if l.spill != nil {
+		if err := l.w.Flush(); err != nil {
+			return err
+		}
+		if _, err := l.spill.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r := bufio.NewReader(l.spill)
+		var data [candidateSize]byte
+		for i := 0; i < l.spilled; i++ {
+			if _, err := io.ReadFull(r, data[:]); err != nil {
+				return fmt.Errorf("read spilled candidates: %w", err)
+			}
+			visit(decodeCandidate(data[:]))
+		}
+		// Restore the write position.
+		if _, err := l.spill.Seek(0, io.SeekEnd); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range l.buf {
+		visit(c)
+	}
+	return nil
+}
+
+// Reset removes all candidates from the list and frees the associated resources.
+func (l *candidateList) Reset() {
+	if l.spill != nil {
+		l.spill.Close()
+		os.Remove(l.spill.Name())
+	}
+	*l = candidateList{spillThreshold: l.spillThreshold}
+}
+
+func (l *candidateList) flush() {
+	if l.err != nil {
+		return
+	}
+	if l.spill == nil {
+		f, err := os.CreateTemp("", "go-consistent-*.candidates")
+		if err != nil {
+			l.err = fmt.Errorf("create spill file: %w", err)
+			return
+		}
+		l.spill = f
+		l.w = bufio.NewWriter(f)
+	}
+	var data [candidateSize]byte
+	for _, c := range l.buf {
+		encodeCandidate(data[:], c)
+		if _, err := l.w.Write(data[:]); err != nil {
+			l.err = fmt.Errorf("spill candidates: %w", err)
+			return
+		}
+	}
+	l.spilled += len(l.buf)
+	l.buf = l.buf[:0]
+}
+
+func encodeCandidate(dst []byte, c candidate) {
+	binary.LittleEndian.PutUint64(dst[0:], uint64(c.loc))
+	binary.LittleEndian.PutUint16(dst[8:], c.variantID)
+	binary.LittleEndian.PutUint16(dst[10:], c.pool)
+	dst[12] = 0
+	if c.quiet {
+		dst[12] |= 1
+	}
+	if c.mismatch {
+		dst[12] |= 2
+	}
+}
+
+func decodeCandidate(data []byte) candidate {
+	return candidate{
+		loc:       location(binary.LittleEndian.Uint64(data[0:])),
+		variantID: binary.LittleEndian.Uint16(data[8:]),
+		pool:      binary.LittleEndian.Uint16(data[10:]),
+		quiet:     data[12]&1 != 0,
+		mismatch:  data[12]&2 != 0,
+	}
+}
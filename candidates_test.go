@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+func TestCandidateListSpill(t *testing.T) {
+	locs := newLocationMap()
+	for _, threshold := range []int{0, 1, 3, 1000} {
+		l := candidateList{spillThreshold: threshold}
+		var want []candidate
+		for i := 0; i < 100; i++ {
+			c := candidate{
+				loc:       locs.Insert(fmt.Sprintf("file%d.go", i%7), i+1, i%80+1),
+				variantID: uint16(i % 13),
+				pool:      uint16(i % 2),
+				quiet:     i%5 == 0,
+				mismatch:  i%7 == 0,
+			}
+			want = append(want, c)
+			l.Append(c)
+		}
+		if l.Len() != len(want) {
+			t.Errorf("threshold=%d: have %d candidates, want %d", threshold, l.Len(), len(want))
+		}
+
+		// Visit twice to check that the spill file is re-read properly.
+		for pass := 0; pass < 2; pass++ {
+			var have []candidate
+			err := l.Visit(func(c candidate) {
+				have = append(have, c)
+			})
+			if err != nil {
+				t.Fatalf("threshold=%d: visit: %v", threshold, err)
+			}
+			if fmt.Sprint(have) != fmt.Sprint(want) {
+				t.Errorf("threshold=%d pass=%d: candidates mismatch", threshold, pass)
+			}
+		}
+		l.Reset()
+	}
+}
+
+func TestLocationMap(t *testing.T) {
+	locs := newLocationMap()
+	tests := []struct {
+		filename     string
+		line, column int
+		wantLine     int
+		wantColumn   int
+	}{
+		{"a.go", 1, 1, 1, 1},
+		{"b.go", 10, 20, 10, 20},
+		{"a.go", 1 << 20, 300, 1 << 20, 300},
+		{"c.go", 1 << 30, 1 << 20, maxLocationLine, maxLocationColumn},
+	}
+	for _, test := range tests {
+		pos := locs.Get(locs.Insert(test.filename, test.line, test.column))
+		if pos.Filename != test.filename || pos.Line != test.wantLine || pos.Column != test.wantColumn {
+			t.Errorf("Insert(%s, %d, %d): got %s", test.filename, test.line, test.column, pos)
+		}
+	}
+	if len(locs.files) != 3 {
+		t.Errorf("have %d interned files, want 3", len(locs.files))
+	}
+}
+
+// BenchmarkCandidateStorage simulates candidates collection for projects of
+// different sizes and reports the heap size that is retained by the storage.
+//
+// With spilling enabled, heap-bytes should stay flat as the number of packages
+// grows; only the interned file table grows (once per file, not per candidate).
+func BenchmarkCandidateStorage(b *testing.B) {
+	const (
+		filesPerPackage      = 10
+		candidatesPerFile    = 200
+		spillThresholdForRun = 4096
+	)
+
+	for _, spill := range []int{0, spillThresholdForRun} {
+		for _, packages := range []int{10, 100, 1000} {
+			name := fmt.Sprintf("spill=%d/packages=%d", spill, packages)
+			b.Run(name, func(b *testing.B) {
+				var heapBytes float64
+				for i := 0; i < b.N; i++ {
+					before := heapInUse()
+					locs := newLocationMap()
+					l := candidateList{spillThreshold: spill}
+					for pkg := 0; pkg < packages; pkg++ {
+						for file := 0; file < filesPerPackage; file++ {
+							filename := fmt.Sprintf("/home/gopher/project/pkg%d/file%d.go", pkg, file)
+							for c := 0; c < candidatesPerFile; c++ {
+								l.Append(candidate{
+									loc:       locs.Insert(filename, c+1, c%80+1),
+									variantID: uint16(c % 30),
+								})
+							}
+						}
+					}
+					heapBytes = float64(heapInUse() - before)
+					runtime.KeepAlive(locs)
+					l.Reset()
+				}
+				b.ReportMetric(heapBytes, "heap-bytes")
+				b.ReportMetric(float64(packages*filesPerPackage*candidatesPerFile), "candidates")
+			})
+		}
+	}
+}
+
+func heapInUse() int64 {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int64(stats.HeapInuse)
+}
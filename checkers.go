@@ -13,14 +13,63 @@ import (
 )
 
 func (ctxt *context) mark(n ast.Node, v *opVariant) {
-	v.count++
+	if ctxt.markHook != nil {
+		ctxt.markHook(n, v)
+	}
 	pos := ctxt.fset.Position(n.Pos())
-	ctxt.candidates = append(ctxt.candidates, candidate{
-		variantID:  v.id,
-		locationID: ctxt.locs.Insert(pos.Filename, pos.Line, pos.Column),
+	ctxt.addCandidate(pos.Filename, pos.Line, pos.Column, v, ctxt.pool, ctxt.quiet, ctxt.mismatch)
+}
+
+// markQuiet is like mark, but the candidate is never reported.
+func (ctxt *context) markQuiet(n ast.Node, v *opVariant) {
+	quiet := ctxt.quiet
+	ctxt.quiet = true
+	ctxt.mark(n, v)
+	ctxt.quiet = quiet
+}
+
+// markMismatch is like mark, but for the nodes that match none of the op variants.
+// The candidate doesn't vote and it's always reported, see opVariant.against.
+func (ctxt *context) markMismatch(n ast.Node, v *opVariant) {
+	ctxt.mismatch = true
+	ctxt.mark(n, v)
+	ctxt.mismatch = false
+}
+
+// addCandidate records the v variant usage at the specified position.
+// Mismatch candidates are recorded without the v usage counting.
+// Pool IDs that don't fit into uint16 are truncated, see context.checkPools.
+func (ctxt *context) addCandidate(filename string, line, column int, v *opVariant, pool int, quiet, mismatch bool) {
+	if !mismatch {
+		v.count++
+		for len(v.poolCounts) <= pool {
+			v.poolCounts = append(v.poolCounts, 0)
+		}
+		v.poolCounts[pool]++
+	}
+	ctxt.candidates.Append(candidate{
+		loc:       ctxt.locs.Insert(filename, line, column),
+		variantID: uint16(v.id),
+		pool:      uint16(pool),
+		quiet:     quiet,
+		mismatch:  mismatch,
 	})
 }
 
+// against returns a variant the v mismatch candidate is reported against.
+// It's v itself, unless v is the suggested variant.
+func (v *opVariant) against(suggested *opVariant) *opVariant {
+	if v != suggested {
+		return v
+	}
+	for _, v2 := range v.op.variants {
+		if v2 != suggested {
+			return v2
+		}
+	}
+	return v
+}
+
 type operation struct {
 	// name is a human-readable operation descriptor.
 	//
@@ -32,6 +81,12 @@ type operation struct {
 	// Updated during the context.assignSuggestions.
 	suggested *opVariant
 
+	// poolSuggested is like suggested, but inferred for every voting pool.
+	// Indexed by the pool ID.
+	//
+	// Updated during the context.assignSuggestions.
+	poolSuggested []*opVariant
+
 	// variants is a list of equivalent operation forms.
 	//
 	// Initialized by checker constructor.
@@ -39,6 +94,11 @@ type operation struct {
 
 	// whether this operation can't be analyzed without types information.
 	needTypes bool
+
+	// severity is a level of the reported inconsistencies, like "error".
+	//
+	// Initialized by checker constructor, can be overridden by the -severity flag.
+	severity string
 }
 
 type opVariant struct {
@@ -65,10 +125,23 @@ type opVariant struct {
 	//
 	// Updated during the context.collectCandidates.
 	count int
+
+	// poolCounts are per-pool usage counters; their sum is equal to the count.
+	// Indexed by the pool ID.
+	//
+	// Updated during the context.collectCandidates.
+	poolCounts []int
 }
 
 type checker interface {
+	// Visit is called for every node of the NodeKinds types.
+	// Returning false skips the node children for this checker only.
 	Visit(n ast.Node) bool
+
+	// NodeKinds returns a list of the node types the checker is interested in,
+	// like (*ast.CallExpr)(nil).
+	NodeKinds() []ast.Node
+
 	Operation() *operation
 }
 
@@ -81,11 +154,6 @@ func (c *checkerBase) Operation() *operation {
 	return c.op
 }
 
-type candidate struct {
-	variantID  int
-	locationID int
-}
-
 type defaultCaseOrderChecker struct {
 	checkerBase
 
@@ -102,10 +170,15 @@ func newDefaultCaseOrderChecker(ctxt *context) checker {
 		name:      "default case order",
 		variants:  []*opVariant{&c.first, &c.last},
 		needTypes: false,
+		severity:  severityWarning,
 	}
 	return c
 }
 
+func (c *defaultCaseOrderChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.TypeSwitchStmt)(nil), (*ast.SwitchStmt)(nil)}
+}
+
 func (c *defaultCaseOrderChecker) Visit(n ast.Node) bool {
 	cases := c.casesList(n)
 	if len(cases) < 2 {
@@ -155,22 +228,35 @@ func newNonZeroLenTestChecker(ctxt *context) checker {
 	c.neq0.warning = "use `len(s) != 0`"
 	c.gt0.warning = "use `len(s) > 0`"
 	c.gte1.warning = "use `len(s) >= 1`"
+	if ctxt.flags.pedantic {
+		// Zero length tests are matched too, they're
+		// the negated forms of the same comparisons.
+		c.neq0.warning = "use `len(s) != 0` and `len(s) == 0`"
+		c.gt0.warning = "use `len(s) > 0` and `len(s) <= 0`"
+		c.gte1.warning = "use `len(s) >= 1` and `len(s) < 1`"
+	}
 	c.op = &operation{
 		name:      "non-zero length test",
 		variants:  []*opVariant{&c.neq0, &c.gt0, &c.gte1},
 		needTypes: true,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *nonZeroLenTestChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.BinaryExpr)(nil)}
+}
+
 func (c *nonZeroLenTestChecker) Visit(n ast.Node) bool {
 	cmp := astcast.ToBinaryExpr(n)
 	call := astcast.ToCallExpr(cmp.X)
 	if len(call.Args) != 1 || astcast.ToIdent(call.Fun).Name != "len" {
 		return true
 	}
+	pedantic := c.ctxt.flags.pedantic
 	x := call.Args[0]
-	if typep.HasStringKind(c.ctxt.info.TypeOf(x)) {
+	if !pedantic && typep.HasStringKind(c.ctxt.info.TypeOf(x)) {
 		return true
 	}
 	switch val := valueOf(cmp.Y); {
@@ -180,10 +266,38 @@ func (c *nonZeroLenTestChecker) Visit(n ast.Node) bool {
 		c.ctxt.mark(n, &c.gt0)
 	case cmp.Op == token.GEQ && val == "1":
 		c.ctxt.mark(n, &c.gte1)
+	case pedantic && cmp.Op == token.EQL && val == "0":
+		c.ctxt.mark(n, &c.neq0)
+	case pedantic && cmp.Op == token.LEQ && val == "0":
+		c.ctxt.mark(n, &c.gt0)
+	case pedantic && cmp.Op == token.LSS && val == "1":
+		c.ctxt.mark(n, &c.gte1)
 	}
 	return true
 }
 
+func (c *nonZeroLenTestChecker) Fix(n ast.Node, to *opVariant) (string, bool) {
+	cmp := astcast.ToBinaryExpr(n)
+	x := c.ctxt.nodeText(cmp.X)
+	zeroTest := cmp.Op == token.EQL || cmp.Op == token.LEQ || cmp.Op == token.LSS
+	switch {
+	case to == &c.neq0 && zeroTest:
+		return x + " == 0", true
+	case to == &c.gt0 && zeroTest:
+		return x + " <= 0", true
+	case to == &c.gte1 && zeroTest:
+		return x + " < 1", true
+	case to == &c.neq0:
+		return x + " != 0", true
+	case to == &c.gt0:
+		return x + " > 0", true
+	case to == &c.gte1:
+		return x + " >= 1", true
+	default:
+		return "", false
+	}
+}
+
 type zeroValPtrAllocChecker struct {
 	checkerBase
 
@@ -200,10 +314,15 @@ func newZeroValPtrAllocChecker(ctxt *context) checker {
 		name:      "zero value ptr alloc",
 		variants:  []*opVariant{&c.newCall, &c.addressOfLit},
 		needTypes: true,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *zeroValPtrAllocChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.CallExpr)(nil), (*ast.UnaryExpr)(nil)}
+}
+
 func (c *zeroValPtrAllocChecker) Visit(n ast.Node) bool {
 	switch n := n.(type) {
 	case *ast.CallExpr:
@@ -213,6 +332,7 @@ func (c *zeroValPtrAllocChecker) Visit(n ast.Node) bool {
 		}
 		typ := c.ctxt.info.TypeOf(n.Args[0])
 		if _, ok := typ.(*types.Basic); ok {
+			// There is no &T{} form for the basic types.
 			return true
 		}
 		c.ctxt.mark(n, &c.newCall)
@@ -230,6 +350,41 @@ func (c *zeroValPtrAllocChecker) Visit(n ast.Node) bool {
 	return true
 }
 
+func (c *zeroValPtrAllocChecker) Fix(n ast.Node, to *opVariant) (string, bool) {
+	switch n := n.(type) {
+	case *ast.UnaryExpr:
+		lit := n.X.(*ast.CompositeLit)
+		if to != &c.newCall || !c.isZeroValLitType(lit.Type) {
+			return "", false
+		}
+		return "new(" + c.ctxt.nodeText(lit.Type) + ")", true
+	case *ast.CallExpr:
+		if to != &c.addressOfLit || !c.isZeroValLitType(n.Args[0]) {
+			return "", false
+		}
+		return "&" + c.ctxt.nodeText(n.Args[0]) + "{}", true
+	default:
+		return "", false
+	}
+}
+
+// isZeroValLitType reports whether new(T) and &T{} are equivalent for the typ type.
+//
+// For the slices and maps, T{} is an empty, but non-nil value,
+// while new(T) points to a nil slice or map; the basic types have no T{} form.
+func (c *zeroValPtrAllocChecker) isZeroValLitType(typ ast.Expr) bool {
+	t := c.ctxt.info.TypeOf(typ)
+	if t == nil {
+		return false
+	}
+	switch t.Underlying().(type) {
+	case *types.Struct, *types.Array:
+		return true
+	default:
+		return false
+	}
+}
+
 type hexLitChecker struct {
 	checkerBase
 
@@ -246,16 +401,28 @@ func newHexLitChecker(ctxt *context) checker {
 		name:      "hex lit",
 		variants:  []*opVariant{&c.lowerCase, &c.upperCase},
 		needTypes: false,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *hexLitChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.BasicLit)(nil)}
+}
+
 func (c *hexLitChecker) Visit(n ast.Node) bool {
 	lit, ok := n.(*ast.BasicLit)
 	if !ok {
 		return true
 	}
-	if lit.Kind != token.INT || !strings.HasPrefix(lit.Value, "0x") {
+	if lit.Kind != token.INT {
+		return false
+	}
+	if c.ctxt.flags.pedantic {
+		c.visitPedantic(lit)
+		return false
+	}
+	if !strings.HasPrefix(lit.Value, "0x") {
 		return false
 	}
 	switch {
@@ -267,6 +434,39 @@ func (c *hexLitChecker) Visit(n ast.Node) bool {
 	return false
 }
 
+// visitPedantic is a Visit version that also handles 0X prefix
+// and the mixed-case literals, like 0xAbC or 0Xff.
+func (c *hexLitChecker) visitPedantic(lit *ast.BasicLit) {
+	if !strings.HasPrefix(lit.Value, "0x") && !strings.HasPrefix(lit.Value, "0X") {
+		return
+	}
+	digits := lit.Value[len("0x"):]
+	lower := strings.ContainsAny(digits, "abcdef")
+	upper := strings.ContainsAny(digits, "ABCDEF")
+	switch {
+	case lower && upper, lower && lit.Value[1] == 'X':
+		// Mixed-case literal is inconsistent with both variants.
+		c.ctxt.markMismatch(lit, &c.lowerCase)
+	case lower:
+		c.ctxt.mark(lit, &c.lowerCase)
+	case upper:
+		c.ctxt.mark(lit, &c.upperCase)
+	}
+}
+
+func (c *hexLitChecker) Fix(n ast.Node, to *opVariant) (string, bool) {
+	lit := n.(*ast.BasicLit)
+	digits := lit.Value[len("0x"):]
+	switch to {
+	case &c.lowerCase:
+		return "0x" + strings.ToLower(digits), true
+	case &c.upperCase:
+		return "0x" + strings.ToUpper(digits), true
+	default:
+		return "", false
+	}
+}
+
 type rangeCheckChecker struct {
 	checkerBase
 
@@ -283,10 +483,15 @@ func newRangeCheckChecker(ctxt *context) checker {
 		name:      "range check",
 		variants:  []*opVariant{&c.alignLeft, &c.alignCenter},
 		needTypes: false,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *rangeCheckChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.BinaryExpr)(nil)}
+}
+
 func (c *rangeCheckChecker) Visit(n ast.Node) bool {
 	e := astcast.ToBinaryExpr(n)
 	if e.Op != token.LAND && e.Op != token.LOR {
@@ -330,10 +535,15 @@ func newAndNotChecker(ctxt *context) checker {
 		name:      "and-not",
 		variants:  []*opVariant{&c.noSpace, &c.withSpace},
 		needTypes: false,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *andNotChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.BinaryExpr)(nil)}
+}
+
 func (c *andNotChecker) Visit(n ast.Node) bool {
 	e := astcast.ToBinaryExpr(n)
 	switch {
@@ -345,6 +555,18 @@ func (c *andNotChecker) Visit(n ast.Node) bool {
 	return true
 }
 
+func (c *andNotChecker) Fix(n ast.Node, to *opVariant) (string, bool) {
+	e := astcast.ToBinaryExpr(n)
+	switch to {
+	case &c.noSpace:
+		return c.ctxt.nodeText(e.X) + " &^ " + c.ctxt.nodeText(astcast.ToUnaryExpr(e.Y).X), true
+	case &c.withSpace:
+		return c.ctxt.nodeText(e.X) + " & ^" + c.ctxt.nodeText(e.Y), true
+	default:
+		return "", false
+	}
+}
+
 type floatLitChecker struct {
 	checkerBase
 
@@ -361,10 +583,15 @@ func newFloatLitChecker(ctxt *context) checker {
 		name:      "float lit",
 		variants:  []*opVariant{&c.explicitIntFrac, &c.implicitIntFrac},
 		needTypes: false,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *floatLitChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.BasicLit)(nil)}
+}
+
 func (c *floatLitChecker) Visit(n ast.Node) bool {
 	lit, ok := n.(*ast.BasicLit)
 	if !ok {
@@ -391,6 +618,34 @@ func (c *floatLitChecker) splitIntFrac(n *ast.BasicLit) (integer, frac string) {
 	return parts[0], parts[1]
 }
 
+func (c *floatLitChecker) Fix(n ast.Node, to *opVariant) (string, bool) {
+	lit := n.(*ast.BasicLit)
+	if strings.ContainsAny(lit.Value, "eEpPxX") {
+		// Literals with exponent and hex literals can't be
+		// rewritten by the int and frac parts concatenation.
+		return "", false
+	}
+	integer, frac := c.splitIntFrac(lit)
+	switch to {
+	case &c.explicitIntFrac:
+		if integer == "" {
+			integer = "0"
+		}
+		if frac == "" {
+			frac = "0"
+		}
+		return integer + "." + frac, true
+	case &c.implicitIntFrac:
+		switch {
+		case integer == "0" && frac != "":
+			return "." + frac, true
+		case integer != "" && frac == "0":
+			return integer + ".", true
+		}
+	}
+	return "", false
+}
+
 type labelCaseChecker struct {
 	checkerBase
 
@@ -420,10 +675,15 @@ func newLabelCaseChecker(ctxt *context) checker {
 			&c.lowerCamelCase,
 		},
 		needTypes: false,
+		severity:  severityWarning,
 	}
 	return c
 }
 
+func (c *labelCaseChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.LabeledStmt)(nil)}
+}
+
 func (c *labelCaseChecker) Visit(n ast.Node) bool {
 	stmt, ok := n.(*ast.LabeledStmt)
 	if !ok {
@@ -456,10 +716,15 @@ func newUntypedConstCoerceChecker(ctxt *context) checker {
 		name:      "untyped const coerce",
 		variants:  []*opVariant{&c.lhsType, &c.rhsType},
 		needTypes: true,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *untypedConstCoerceChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.GenDecl)(nil)}
+}
+
 func (c *untypedConstCoerceChecker) Visit(n ast.Node) bool {
 	decl, ok := n.(*ast.GenDecl)
 	if !ok {
@@ -500,7 +765,11 @@ func (c *untypedConstCoerceChecker) isUntypedConst(e ast.Expr) bool {
 	case *ast.BasicLit:
 		return true
 	case *ast.Ident:
-		typ, ok := c.ctxt.info.ObjectOf(e).Type().(*types.Basic)
+		obj := c.ctxt.info.ObjectOf(e)
+		if obj == nil {
+			return false
+		}
+		typ, ok := obj.Type().(*types.Basic)
 		return ok && typ.Info()&types.IsUntyped != 0
 	case *ast.BinaryExpr:
 		return c.isUntypedConst(e.X) && c.isUntypedConst(e.Y)
@@ -529,10 +798,15 @@ func newEmptyMapChecker(ctxt *context) checker {
 		name:      "empty map",
 		variants:  []*opVariant{&c.makeCall, &c.mapLit},
 		needTypes: true,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *emptyMapChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.CallExpr)(nil), (*ast.CompositeLit)(nil)}
+}
+
 func (c *emptyMapChecker) Visit(n ast.Node) bool {
 	switch n := n.(type) {
 	case *ast.CallExpr:
@@ -550,7 +824,7 @@ func (c *emptyMapChecker) Visit(n ast.Node) bool {
 		c.ctxt.mark(n, &c.makeCall)
 	case *ast.CompositeLit:
 		// Avoid &map[K]V{}, since it's a new(map[K]V), not make(map[K]V).
-		unExpr, ok := c.ctxt.astinfo.Parents[n].(*ast.UnaryExpr)
+		unExpr, ok := c.ctxt.walker.Parent().(*ast.UnaryExpr)
 		if ok && unExpr.Op == token.AND {
 			return true
 		}
@@ -566,6 +840,23 @@ func (c *emptyMapChecker) Visit(n ast.Node) bool {
 	return true
 }
 
+func (c *emptyMapChecker) Fix(n ast.Node, to *opVariant) (string, bool) {
+	switch n := n.(type) {
+	case *ast.CompositeLit:
+		if to != &c.makeCall {
+			return "", false
+		}
+		return "make(" + c.ctxt.nodeText(n.Type) + ")", true
+	case *ast.CallExpr:
+		if to != &c.mapLit {
+			return "", false
+		}
+		return c.ctxt.nodeText(n.Args[0]) + "{}", true
+	default:
+		return "", false
+	}
+}
+
 type emptySliceChecker struct {
 	checkerBase
 
@@ -582,10 +873,15 @@ func newEmptySliceChecker(ctxt *context) checker {
 		name:      "empty slice",
 		variants:  []*opVariant{&c.makeCall, &c.sliceLit},
 		needTypes: true,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *emptySliceChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.CallExpr)(nil), (*ast.CompositeLit)(nil)}
+}
+
 func (c *emptySliceChecker) Visit(n ast.Node) bool {
 	switch n := n.(type) {
 	case *ast.CallExpr:
@@ -603,7 +899,7 @@ func (c *emptySliceChecker) Visit(n ast.Node) bool {
 		c.ctxt.mark(n, &c.makeCall)
 	case *ast.CompositeLit:
 		// Avoid &[]T{}, since it's a new([]T), not make([]T, 0).
-		unExpr, ok := c.ctxt.astinfo.Parents[n].(*ast.UnaryExpr)
+		unExpr, ok := c.ctxt.walker.Parent().(*ast.UnaryExpr)
 		if ok && unExpr.Op == token.AND {
 			return true
 		}
@@ -619,6 +915,23 @@ func (c *emptySliceChecker) Visit(n ast.Node) bool {
 	return true
 }
 
+func (c *emptySliceChecker) Fix(n ast.Node, to *opVariant) (string, bool) {
+	switch n := n.(type) {
+	case *ast.CompositeLit:
+		if to != &c.makeCall {
+			return "", false
+		}
+		return "make(" + c.ctxt.nodeText(n.Type) + ", 0)", true
+	case *ast.CallExpr:
+		if to != &c.sliceLit {
+			return "", false
+		}
+		return c.ctxt.nodeText(n.Args[0]) + "{}", true
+	default:
+		return "", false
+	}
+}
+
 type argListParensChecker struct {
 	checkerBase
 
@@ -635,10 +948,15 @@ func newArgListParensChecker(ctxt *context) checker {
 		name:      "arg list parens",
 		variants:  []*opVariant{&c.sameLine, &c.nextLine},
 		needTypes: false,
+		severity:  severityInfo,
 	}
 	return c
 }
 
+func (c *argListParensChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.CallExpr)(nil)}
+}
+
 func (c *argListParensChecker) Visit(n ast.Node) bool {
 	call, ok := n.(*ast.CallExpr)
 	if !ok || len(call.Args) < 2 {
@@ -677,10 +995,15 @@ func newUnitImportChecker(ctxt *context) checker {
 		name:      "unit import",
 		variants:  []*opVariant{&c.noParens, &c.withParens},
 		needTypes: false,
+		severity:  severityError,
 	}
 	return c
 }
 
+func (c *unitImportChecker) NodeKinds() []ast.Node {
+	return []ast.Node{(*ast.GenDecl)(nil)}
+}
+
 func (c *unitImportChecker) Visit(n ast.Node) bool {
 	decl, ok := n.(*ast.GenDecl)
 	if ok && decl.Tok == token.IMPORT && len(decl.Specs) == 1 {
@@ -692,3 +1015,19 @@ func (c *unitImportChecker) Visit(n ast.Node) bool {
 	}
 	return false
 }
+
+func (c *unitImportChecker) Fix(n ast.Node, to *opVariant) (string, bool) {
+	decl := n.(*ast.GenDecl)
+	spec := decl.Specs[0].(*ast.ImportSpec)
+	if spec.Doc != nil || spec.Comment != nil {
+		return "", false // Don't lose the comments
+	}
+	switch to {
+	case &c.noParens:
+		return "import " + c.ctxt.nodeText(spec), true
+	case &c.withParens:
+		return "import (\n\t" + c.ctxt.nodeText(spec) + "\n)", true
+	default:
+		return "", false
+	}
+}
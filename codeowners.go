@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// codeOwners maps files to their owners using the CODEOWNERS file rules.
+//
+// Both GitHub and GitLab syntax is supported: within a section,
+// the last matching pattern wins; owners from all GitLab sections
+// are combined. Patterns are matched against the slash-separated
+// paths relative to the repository root.
+//
+// See https://docs.github.com/en/repositories/managing-your-repositorys-settings-and-features/customizing-your-repository/about-code-owners
+// and https://docs.gitlab.com/ee/user/project/codeowners/reference.html
+type codeOwners struct {
+	root     string
+	sections []codeOwnersSection
+
+	// cache maps file names to their owners.
+	cache map[string][]string
+}
+
+type codeOwnersSection struct {
+	rules []codeOwnersRule
+}
+
+type codeOwnersRule struct {
+	re     *regexp.Regexp
+	owners []string
+}
+
+// Report grouping modes (see -group-by flag).
+const (
+	groupByOwner = "owner"
+)
+
+// unownedGroup is a -group-by=owner group name for the files without owners.
+const unownedGroup = "(unowned)"
+
+// loadCodeOwners parses the CODEOWNERS file.
+// root is a directory the patterns are relative to.
+func loadCodeOwners(filename, root string) (*codeOwners, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	co, err := parseCodeOwners(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	co.root = root
+	return co, nil
+}
+
+func parseCodeOwners(r io.Reader) (*codeOwners, error) {
+	co := &codeOwners{
+		sections: []codeOwnersSection{{}},
+		cache:    make(map[string][]string),
+	}
+	var sectionOwners []string
+	s := bufio.NewScanner(r)
+	for lineNum := 1; s.Scan(); lineNum++ {
+		fields := splitCodeOwnersLine(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		// GitLab section header: [Section] or ^[Section][N],
+		// optionally followed by the default section owners.
+		if header := strings.TrimPrefix(fields[0], "^"); strings.HasPrefix(header, "[") {
+			if !strings.Contains(strings.Join(fields, " "), "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNum)
+			}
+			// Section names can contain spaces, skip the fields until the closing bracket.
+			i := 0
+			for !strings.Contains(fields[i], "]") {
+				i++
+			}
+			if rest := fields[i][strings.LastIndex(fields[i], "]")+1:]; rest != "" && !strings.HasPrefix(rest, "[") {
+				return nil, fmt.Errorf("line %d: unexpected %q after section name", lineNum, rest)
+			}
+			sectionOwners = fields[i+1:]
+			co.sections = append(co.sections, codeOwnersSection{})
+			continue
+		}
+
+		re, err := compileCodeOwnersPattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		owners := fields[1:]
+		if len(owners) == 0 {
+			owners = sectionOwners
+		}
+		section := &co.sections[len(co.sections)-1]
+		section.rules = append(section.rules, codeOwnersRule{re: re, owners: owners})
+	}
+	return co, s.Err()
+}
+
+// splitCodeOwnersLine returns the line fields without the comment.
+// Backslash escapes the whitespace and the # characters.
+func splitCodeOwnersLine(line string) []string {
+	var fields []string
+	var field strings.Builder
+	inField := false
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case ch == '\\' && i+1 < len(line):
+			i++
+			// Keep the escape for the pattern compiler,
+			// unless it's a char that only matters for the splitting.
+			if next := line[i]; next != ' ' && next != '\t' && next != '#' {
+				field.WriteByte('\\')
+			}
+			field.WriteByte(line[i])
+			inField = true
+		case ch == '#':
+			i = len(line)
+		case ch == ' ' || ch == '\t':
+			if inField {
+				fields = append(fields, field.String())
+				field.Reset()
+				inField = false
+			}
+		default:
+			field.WriteByte(ch)
+			inField = true
+		}
+	}
+	if inField {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// compileCodeOwnersPattern converts a gitignore-like pattern to a regexp.
+//
+// Patterns with a slash at the beginning or in the middle are anchored
+// to the root, the others match at any depth. A pattern that matches
+// a directory also matches all its files, except for the patterns
+// that end with "/*": they only match the direct directory children.
+func compileCodeOwnersPattern(pattern string) (*regexp.Regexp, error) {
+	anchored := strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	directOnly := strings.HasSuffix(pattern, "/*") && !strings.HasSuffix(pattern, "/**/*")
+	pattern = strings.TrimSuffix(pattern, "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+
+	var buf strings.Builder
+	if anchored {
+		buf.WriteString("^")
+	} else {
+		buf.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			buf.WriteString("(?:.*/)?")
+			i += len("**/") - 1
+		case pattern[i:] == "**":
+			buf.WriteString(".*")
+			i++
+		case ch == '*':
+			buf.WriteString("[^/]*")
+		case ch == '?':
+			buf.WriteString("[^/]")
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	if directOnly {
+		buf.WriteString("$")
+	} else {
+		buf.WriteString("(?:/.*)?$")
+	}
+	return regexp.Compile(buf.String())
+}
+
+// Owners returns the filename owners.
+// Returns nil if the file has no owners or it's located outside of the root.
+func (co *codeOwners) Owners(filename string) []string {
+	if owners, ok := co.cache[filename]; ok {
+		return owners
+	}
+	owners := co.match(filename)
+	co.cache[filename] = owners
+	return owners
+}
+
+func (co *codeOwners) match(filename string) []string {
+	rel, err := filepath.Rel(co.root, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	var owners []string
+	seen := make(map[string]bool)
+	for _, section := range co.sections {
+		// The last matching rule takes the precedence.
+		for i := len(section.rules) - 1; i >= 0; i-- {
+			rule := section.rules[i]
+			if !rule.re.MatchString(rel) {
+				continue
+			}
+			for _, owner := range rule.owners {
+				if !seen[owner] {
+					seen[owner] = true
+					owners = append(owners, owner)
+				}
+			}
+			break
+		}
+	}
+	return owners
+}
+
+// ownersSuffix returns a " (owners ...)"-like text report suffix.
+func ownersSuffix(owners []string) string {
+	if len(owners) == 0 {
+		return ""
+	}
+	return " (owners " + strings.Join(owners, " ") + ")"
+}
+
+// groupWarningsByOwner returns warnings grouped by their owners.
+// Warnings with several owners are included into every owner group.
+// Owners are listed in the order of their first warning, with the
+// unowned warnings group at the end.
+func groupWarningsByOwner(warnings []warning) (owners []string, groups map[string][]warning) {
+	groups = make(map[string][]warning)
+	add := func(owner string, w warning) {
+		if _, ok := groups[owner]; !ok && owner != unownedGroup {
+			owners = append(owners, owner)
+		}
+		groups[owner] = append(groups[owner], w)
+	}
+	for _, w := range warnings {
+		if len(w.owners) == 0 {
+			add(unownedGroup, w)
+			continue
+		}
+		for _, owner := range w.owners {
+			add(owner, w)
+		}
+	}
+	if _, ok := groups[unownedGroup]; ok {
+		owners = append(owners, unownedGroup)
+	}
+	return owners, groups
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodeOwners(t *testing.T) {
+	const codeowners = `
+# Default owners.
+*                    @org/everyone
+
+*.md                 @org/docs
+/build/logs/         @org/build
+apps/                @org/apps
+/docs/*              @org/docs-top
+**/vendor            @org/deps
+cmd/**/main.go       @org/cmd
+file\ with\ spaces.go @alice
+/unowned/
+
+[Go] @org/go-default
+*.go
+/internal/ @org/internal
+
+^[Optional][2]
+*_test.go @org/qa
+`
+	co, err := parseCodeOwners(strings.NewReader(codeowners))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	co.root = "/repo"
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"README.txt", []string{"@org/everyone"}},
+		{"README.md", []string{"@org/docs"}},
+		{"sub/dir/notes.md", []string{"@org/docs"}},
+		{"build/logs/a.log", []string{"@org/build"}},
+		{"sub/build/logs/a.log", []string{"@org/everyone"}},
+		{"apps/a.txt", []string{"@org/apps"}},
+		{"sub/apps/deep/a.txt", []string{"@org/apps"}},
+		{"docs/a.txt", []string{"@org/docs-top"}},
+		{"docs/nested/a.txt", []string{"@org/everyone"}},
+		{"vendor/x/y.txt", []string{"@org/deps"}},
+		{"a/b/vendor/y.txt", []string{"@org/deps"}},
+		{"cmd/main.go", []string{"@org/cmd", "@org/go-default"}},
+		{"cmd/tool/v2/main.go", []string{"@org/cmd", "@org/go-default"}},
+		{"file with spaces.go", []string{"@alice", "@org/go-default"}},
+		{"unowned/a.txt", nil},
+		{"internal/a.go", []string{"@org/everyone", "@org/internal"}},
+		{"internal/a_test.go", []string{"@org/everyone", "@org/internal", "@org/qa"}},
+	}
+	for _, test := range tests {
+		have := co.Owners(filepath.Join("/repo", filepath.FromSlash(test.path)))
+		if !reflect.DeepEqual(have, test.want) {
+			t.Errorf("%s: have %q owners, want %q", test.path, have, test.want)
+		}
+	}
+
+	if owners := co.Owners("/elsewhere/a.go"); owners != nil {
+		t.Errorf("file outside of the root: have %q owners, want none", owners)
+	}
+}
+
+func TestReportGroupByOwner(t *testing.T) {
+	root, err := filepath.Abs("testdata/tests_separate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := parseCodeOwners(strings.NewReader("*_test.go @qa\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	co.root = root
+
+	var ctxt context
+	ctxt.codeowners = co
+	ctxt.flags.groupBy = groupByOwner
+	out := runReport(t, &ctxt, "./testdata/tests_separate", "text", 3)
+
+	var groups []string
+	for _, line := range strings.Split(out, "\n") {
+		if line != "" && !strings.Contains(line, ".go:") {
+			groups = append(groups, line)
+		}
+	}
+	if len(groups) != 2 || !strings.HasPrefix(groups[0], "@qa: ") || !strings.HasPrefix(groups[1], unownedGroup+": ") {
+		t.Errorf("unexpected groups %q:\n%s", groups, out)
+	}
+	if !strings.Contains(out, "alloc_test.go") || !strings.Contains(out, "(owners @qa)") {
+		t.Errorf("test file warnings are not attributed to @qa:\n%s", out)
+	}
+}
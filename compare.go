@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// runCompare implements the "compare" subcommand.
+//
+// It checks out both revisions into the temporary git worktrees,
+// analyzes them and reports how the variants distribution has changed.
+// Returns an error if any of the operations suggested variants was flipped.
+//
+// Usage: go-consistent compare [flags] REV1 REV2 [targets]
+//
+// If targets are not specified, ./... is used.
+func runCompare(args []string) error {
+	var ctxt context
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	if err := ctxt.parseArgs(fs, args); err != nil {
+		return err
+	}
+	if len(ctxt.flags.targets) < 2 {
+		return errors.New("usage: go-consistent compare [flags] REV1 REV2 [targets]")
+	}
+	rev1, rev2 := ctxt.flags.targets[0], ctxt.flags.targets[1]
+	targets := ctxt.flags.targets[2:]
+	if len(targets) == 0 {
+		targets = []string{"./..."}
+	}
+
+	shifts, err := compareRevisions(&ctxt, rev1, rev2, targets)
+	if err != nil {
+		return err
+	}
+	flips := printShifts(os.Stdout, rev1, rev2, shifts)
+	if flips != 0 {
+		return fmt.Errorf("%d %s changed the suggested variant", flips, plural(flips, "operation"))
+	}
+	return nil
+}
+
+// opShift describes an operation variants distribution change between two revisions.
+type opShift struct {
+	name     string
+	variants []string
+
+	// counts and suggested are indexed by the revision (0 or 1).
+	counts    [2][]int
+	suggested [2]int
+}
+
+func (s *opShift) total(rev int) int {
+	total := 0
+	for _, n := range s.counts[rev] {
+		total += n
+	}
+	return total
+}
+
+// flipped reports whether the suggested variant has changed.
+// Operations that are not used in one of the revisions are never flipped.
+func (s *opShift) flipped() bool {
+	return s.total(0) != 0 && s.total(1) != 0 && s.suggested[0] != s.suggested[1]
+}
+
+func (s *opShift) changed() bool {
+	for i := range s.variants {
+		if s.counts[0][i] != s.counts[1][i] {
+			return true
+		}
+	}
+	return false
+}
+
+// compareRevisions analyzes the targets at both revisions.
+// Targets are interpreted relative to the working directory.
+func compareRevisions(ctxt *context, rev1, rev2 string, targets []string) ([]*opShift, error) {
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := gitOutput("rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+
+	var shifts []*opShift
+	for rev, name := range []string{rev1, rev2} {
+		ops, err := analyzeRevision(ctxt, root, prefix, name, targets)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if shifts == nil {
+			for _, op := range ops {
+				s := &opShift{name: op.name}
+				for _, v := range op.variants {
+					s.variants = append(s.variants, v.warning)
+				}
+				shifts = append(shifts, s)
+			}
+		}
+		// Both revisions are analyzed by the same checkers set,
+		// so the operations are listed in the same order.
+		for i, op := range ops {
+			s := shifts[i]
+			for j, v := range op.variants {
+				s.counts[rev] = append(s.counts[rev], v.count)
+				if v == op.suggested {
+					s.suggested[rev] = j
+				}
+			}
+		}
+	}
+	return shifts, nil
+}
+
+// analyzeRevision checks out the rev into a temporary worktree
+// and returns the analyzed operations.
+func analyzeRevision(base *context, root, prefix, rev string, targets []string) ([]*operation, error) {
+	dir, err := os.MkdirTemp("", "go-consistent-compare-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if _, err := gitOutput("-C", root, "worktree", "add", "--detach", dir, rev); err != nil {
+		return nil, err
+	}
+	defer gitOutput("-C", root, "worktree", "remove", "--force", dir)
+
+	// Targets are relative to the working directory,
+	// so the analysis is performed from inside the worktree.
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	workDir := filepath.Join(dir, filepath.FromSlash(prefix))
+	if err := os.Chdir(workDir); err != nil {
+		return nil, err
+	}
+	defer os.Chdir(wd)
+
+	// The base context only has the parsed flags set,
+	// so it's safe to use a copy of it.
+	ctxt := *base
+	ctxt.flags.targets = targets
+	// Worktree paths are temporary, so the cache entries would never be reused.
+	ctxt.flags.cache = cacheOff
+	ctxt.workDir = workDir
+
+	steps := []struct {
+		name string
+		fn   func() error
+	}{
+		{"resolve targets", ctxt.resolveTargets},
+		{"init checkers", ctxt.initCheckers},
+		{"collect candidates", ctxt.collectAllCandidates},
+		{"assign suggestions", ctxt.assignSuggestions},
+	}
+	for _, step := range steps {
+		if err := step.fn(); err != nil {
+			return nil, fmt.Errorf("%s: %w", step.name, err)
+		}
+	}
+	ctxt.candidates.Reset()
+
+	ops := make([]*operation, len(ctxt.checkers))
+	for i, c := range ctxt.checkers {
+		ops[i] = c.Operation()
+	}
+	return ops, nil
+}
+
+// printShifts prints the changed operations and returns
+// the number of operations whose suggested variant was flipped.
+func printShifts(w io.Writer, rev1, rev2 string, shifts []*opShift) int {
+	flips := 0
+	changed := 0
+	for _, s := range shifts {
+		if !s.changed() {
+			continue
+		}
+		changed++
+		fmt.Fprintf(w, "%s:\n", s.name)
+		for i, v := range s.variants {
+			before, after := s.counts[0][i], s.counts[1][i]
+			fmt.Fprintf(w, "  %s: %d (%d%%) -> %d (%d%%), %+d\n", v,
+				before, percentOf(before, s.total(0)),
+				after, percentOf(after, s.total(1)),
+				after-before)
+		}
+		if s.flipped() {
+			flips++
+			fmt.Fprintf(w, "  suggested variant flipped: %s -> %s\n",
+				s.variants[s.suggested[0]], s.variants[s.suggested[1]])
+		}
+	}
+	if changed == 0 {
+		fmt.Fprintf(w, "no changes between %s and %s\n", rev1, rev2)
+	}
+	return flips
+}
+
+func percentOf(n, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return n * 100 / total
+}
+
+// gitOutput runs git with the specified args and returns its trimmed output.
+func gitOutput(args ...string) (string, error) {
+	out, err := gitRawOutput(args...)
+	return strings.TrimSpace(string(out)), err
+}
+
+// gitRawOutput runs git with the specified args and returns its output.
+func gitRawOutput(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
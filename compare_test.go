@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareRevisions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=gopher", "GIT_AUTHOR_EMAIL=gopher@example.com",
+			"GIT_COMMITTER_NAME=gopher", "GIT_COMMITTER_EMAIL=gopher@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+	}
+	commit := func(src string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		git("add", "-A")
+		git("commit", "-q", "-m", "update")
+	}
+
+	git("init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	commit(`package foo
+
+var (
+	_ = make(map[int]int)
+	_ = make(map[int]int)
+	_ = map[int]int{}
+)
+`)
+	commit(`package foo
+
+var (
+	_ = make(map[int]int)
+	_ = map[int]int{}
+	_ = map[int]int{}
+)
+`)
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	var ctxt context
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	if err := ctxt.parseArgs(fs, []string{"-cache=off"}); err != nil {
+		t.Fatal(err)
+	}
+	shifts, err := compareRevisions(&ctxt, "HEAD~1", "HEAD", []string{"./..."})
+	if err != nil {
+		t.Fatalf("compare: %v", err)
+	}
+
+	var out strings.Builder
+	if flips := printShifts(&out, "HEAD~1", "HEAD", shifts); flips != 1 {
+		t.Errorf("have %d flips, want 1", flips)
+	}
+	want := `empty map:
+  use make(map[K]V): 2 (66%) -> 1 (33%), -1
+  use map[K]V{}: 1 (33%) -> 2 (66%), +1
+  suggested variant flipped: use make(map[K]V) -> use map[K]V{}
+`
+	if out.String() != want {
+		t.Errorf("output mismatch:\nhave:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
@@ -1,8 +1,8 @@
 package main
 
 import (
-	"go/token"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/quasilyte/go-consistent/internal/end2end"
@@ -16,6 +16,7 @@ func TestEnd2End(t *testing.T) {
 		"negative_tests1.go",
 		"negative_tests2.go",
 		"negative_tests3.go",
+		"pedantic_tests.go",
 	}
 
 	for _, filename := range filenames {
@@ -27,14 +28,11 @@ func TestEnd2End(t *testing.T) {
 			}
 
 			var ctxt context
-			ctxt.paths = []string{rel}
-			_ = ctxt.initCheckers()
-			if err := ctxt.collectAllCandidates(); err != nil {
-				t.Fatalf("collect candidates: %v", err)
-			}
-			_ = ctxt.assignSuggestions()
-			visitWarnings(&ctxt, func(pos token.Position, v *opVariant) {
-				text := v.op.name + ": " + v.op.suggested.warning
+			ctxt.flags.pedantic = strings.HasPrefix(filename, "pedantic")
+			analyzeTestdata(t, &ctxt, rel)
+			err = visitWarnings(&ctxt, func(w warning) {
+				pos := w.pos
+				text := w.variant.op.name + ": " + w.suggested.warning
 				mlist, ok := f.Matchers[pos.Line]
 				if !ok {
 					t.Errorf("%s: unexpected warning: %s", pos, text)
@@ -50,6 +48,9 @@ func TestEnd2End(t *testing.T) {
 					}
 				}
 			})
+			if err != nil {
+				t.Fatalf("visit warnings: %v", err)
+			}
 
 			for _, mlist := range f.Matchers {
 				for _, m := range mlist {
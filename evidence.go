@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+)
+
+// suggestionEvidence holds the examples of the suggested variant usages.
+//
+// It answers the "why this variant is suggested?" question
+// when a warning is disputed.
+type suggestionEvidence struct {
+	op     *operation
+	poolID int
+	pool   string
+
+	// examples are the first locations that use the suggested variant.
+	examples []token.Position
+
+	// warnings is a number of inconsistencies reported for this suggestion.
+	warnings int
+}
+
+// evidenceCollector gathers the suggestion evidence
+// for the warnings that were reported.
+type evidenceCollector struct {
+	limit int
+
+	list  []*suggestionEvidence
+	index map[suggestionKey]*suggestionEvidence
+}
+
+// suggestionKey identifies a suggestion: an operation inside a voting pool.
+type suggestionKey struct {
+	op     *operation
+	poolID int
+}
+
+func newEvidenceCollector(limit int) *evidenceCollector {
+	return &evidenceCollector{
+		limit: limit,
+		index: make(map[suggestionKey]*suggestionEvidence),
+	}
+}
+
+// Add records a candidate described by w.
+func (ec *evidenceCollector) Add(w warning) {
+	key := suggestionKey{op: w.variant.op, poolID: w.poolID}
+	e := ec.index[key]
+	if e == nil {
+		e = &suggestionEvidence{op: w.variant.op, poolID: w.poolID, pool: w.pool}
+		ec.index[key] = e
+		ec.list = append(ec.list, e)
+	}
+	switch {
+	case w.variant != w.suggested:
+		if !w.quiet {
+			e.warnings++
+		}
+	case len(e.examples) < ec.limit:
+		e.examples = append(e.examples, w.pos)
+	}
+}
+
+// Print writes the evidence for every suggestion that caused any warnings.
+func (ec *evidenceCollector) Print(ctxt *context, w io.Writer) {
+	opOrder := make(map[*operation]int)
+	for i, c := range ctxt.checkers {
+		opOrder[c.Operation()] = i
+	}
+	sort.SliceStable(ec.list, func(i, j int) bool {
+		x, y := ec.list[i], ec.list[j]
+		if x.op != y.op {
+			return opOrder[x.op] < opOrder[y.op]
+		}
+		return x.poolID < y.poolID
+	})
+
+	for _, e := range ec.list {
+		if e.warnings == 0 {
+			continue
+		}
+		suggested := e.op.suggestedFor(e.poolID)
+		total := 0
+		for _, v := range e.op.variants {
+			total += v.poolCount(e.poolID)
+		}
+		name := e.op.name
+		if e.pool != "" {
+			name += " (" + e.pool + ")"
+		}
+		fmt.Fprintf(w, "evidence: %s: %s: %d of %d usages\n",
+			name, suggested.warning, suggested.poolCount(e.poolID), total)
+		for _, pos := range e.examples {
+			loc := pos.String()
+			if ctxt.flags.shorterErrLocation {
+				loc = ctxt.shortenLocation(loc)
+			}
+			fmt.Fprintf(w, "\t%s\n", loc)
+		}
+	}
+}
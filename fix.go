@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"go/token"
+)
+
+// fixer is implemented by the checkers that can rewrite
+// a candidate into another variant of the same operation.
+type fixer interface {
+	// Fix returns a replacement for the n node source text
+	// that makes it use the to variant.
+	// Returns false if such rewrite is not possible.
+	//
+	// n is a node that was passed to the context.mark.
+	// Fix is only valid while the n containing file is being analyzed.
+	Fix(n ast.Node, to *opVariant) (string, bool)
+}
+
+// textEdit is a source code replacement.
+type textEdit struct {
+	pos token.Position
+	end token.Position
+
+	newText string
+}
+
+// suggestFix returns an edit that rewrites n so it uses the to variant.
+// Returns false if the checker that produced n can't do it.
+func (ctxt *context) suggestFix(c checker, n ast.Node, to *opVariant) (textEdit, bool) {
+	f, ok := c.(fixer)
+	if !ok {
+		return textEdit{}, false
+	}
+	newText, ok := f.Fix(n, to)
+	if !ok {
+		return textEdit{}, false
+	}
+	return textEdit{
+		pos:     ctxt.fset.Position(n.Pos()),
+		end:     ctxt.fset.Position(n.End()),
+		newText: newText,
+	}, true
+}
+
+// nodeText returns a formatted n source text.
+func (ctxt *context) nodeText(n ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, ctxt.fset, n); err != nil {
+		return ""
+	}
+	return buf.String()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"reflect"
+	"testing"
+)
+
+func TestFix(t *testing.T) {
+	tests := []struct {
+		op       string
+		pedantic bool
+		src      string
+
+		// to is a 1-based variant index to fix the candidates to.
+		to int
+
+		// want are the fixed texts for every candidate that doesn't use
+		// the to variant, in the source order; "" means not fixable.
+		want []string
+	}{
+		{op: "zero-value-ptr-alloc", to: 1, src: `type T struct{}; var _ = &T{}`, want: []string{"new(T)"}},
+		{op: "zero-value-ptr-alloc", to: 1, src: `var _ = &[2]int{}`, want: []string{"new([2]int)"}},
+		{op: "zero-value-ptr-alloc", to: 1, src: `var _ = &map[string]int{}`, want: []string{""}},
+		{op: "zero-value-ptr-alloc", to: 1, src: `var _ = &[]int{}`, want: []string{""}},
+		{op: "zero-value-ptr-alloc", to: 1, src: `type M map[int]int; var _ = &M{}`, want: []string{""}},
+		{op: "zero-value-ptr-alloc", to: 2, src: `type T struct{}; var _ = new(T)`, want: []string{"&T{}"}},
+		{op: "zero-value-ptr-alloc", to: 2, src: `var _ = new([2]int)`, want: []string{"&[2]int{}"}},
+		{op: "zero-value-ptr-alloc", to: 2, src: `var _ = new(map[string]int)`, want: []string{""}},
+		{op: "zero-value-ptr-alloc", to: 2, src: `var _ = new([]int)`, want: []string{""}},
+		{op: "zero-value-ptr-alloc", to: 2, src: `type B int; var _ = new(B)`, want: []string{""}},
+		{op: "zero-value-ptr-alloc", to: 2, src: `var _ = new(int)`, want: nil},
+		{op: "zero-value-ptr-alloc", to: 2, pedantic: true, src: `var _ = new(int)`, want: nil},
+
+		{op: "empty-map", to: 2, src: `var _ = make(map[int]int)`, want: []string{"map[int]int{}"}},
+		{op: "empty-map", to: 2, src: `var _ = make(map[int]int, 0)`, want: []string{"map[int]int{}"}},
+		{op: "empty-map", to: 1, src: `var _ = map[int]int{}`, want: []string{"make(map[int]int)"}},
+
+		{op: "empty-slice", to: 2, src: `var _ = make([]int, 0)`, want: []string{"[]int{}"}},
+		{op: "empty-slice", to: 1, src: `var _ = []int{}`, want: []string{"make([]int, 0)"}},
+
+		{op: "hex-lit", to: 1, src: `var _ = 0xFF`, want: []string{"0xff"}},
+		{op: "hex-lit", to: 2, src: `var _ = 0xabc`, want: []string{"0xABC"}},
+		{op: "hex-lit", to: 1, pedantic: true, src: `var _ = 0xAbC`, want: []string{"0xabc"}},
+		{op: "hex-lit", to: 2, pedantic: true, src: `var _ = 0xAbC`, want: []string{"0xABC"}},
+		{op: "hex-lit", to: 2, pedantic: true, src: `var _ = 0Xab`, want: []string{"0xAB"}},
+
+		{op: "and-not", to: 1, src: `var x, y int; var _ = x & ^y`, want: []string{"x &^ y"}},
+		{op: "and-not", to: 2, src: `var x, y int; var _ = x &^ y`, want: []string{"x & ^y"}},
+
+		{op: "float-lit", to: 1, src: `var _ = .5`, want: []string{"0.5"}},
+		{op: "float-lit", to: 1, src: `var _ = 1.`, want: []string{"1.0"}},
+		{op: "float-lit", to: 2, src: `var _ = 1.0`, want: []string{"1."}},
+		{op: "float-lit", to: 2, src: `var _ = 0.5`, want: []string{".5"}},
+		{op: "float-lit", to: 1, src: `var _ = 1e5`, want: []string{""}},
+		{op: "float-lit", to: 1, src: `var _ = .5e3`, want: []string{""}},
+		{op: "float-lit", to: 1, src: `var _ = 0x1p-2`, want: []string{""}},
+		{op: "float-lit", to: 2, src: `var _ = 0.5e3`, want: []string{""}},
+
+		{op: "non-zero-length-test", to: 1, src: `var s []int; var _ = len(s) > 0`, want: []string{"len(s) != 0"}},
+		{op: "non-zero-length-test", to: 2, src: `var s []int; var _ = len(s) >= 1`, want: []string{"len(s) > 0"}},
+		{op: "non-zero-length-test", to: 3, src: `var s []int; var _ = len(s) != 0`, want: []string{"len(s) >= 1"}},
+		{op: "non-zero-length-test", to: 2, pedantic: true, src: `var s string; var _ = len(s) == 0`, want: []string{"len(s) <= 0"}},
+		{op: "non-zero-length-test", to: 3, pedantic: true, src: `var s string; var _ = len(s) <= 0`, want: []string{"len(s) < 1"}},
+
+		{op: "unit-import", to: 1, src: "import (\n\t\"fmt\"\n)\n\nvar _ = fmt.Sprint", want: []string{`import "fmt"`}},
+		{op: "unit-import", to: 2, src: "import \"fmt\"\n\nvar _ = fmt.Sprint", want: []string{"import (\n\t\"fmt\"\n)"}},
+		{op: "unit-import", to: 1, src: "import (\n\t\"fmt\" // Printing\n)\n\nvar _ = fmt.Sprint", want: []string{""}},
+
+		// Checkers without a fixer.
+		{op: "arg-list-parens", to: 1, src: "var _ = append([]int{},\n\t1,\n)", want: []string{""}},
+		{op: "range-check", to: 1, src: `var x int; var _ = 0 < x && x < 10`, want: []string{""}},
+	}
+
+	for _, test := range tests {
+		have := runFix(t, test.op, test.pedantic, "package p\n\n"+test.src+"\n", test.to)
+		if !reflect.DeepEqual(have, test.want) {
+			t.Errorf("%s: %q: to %d:\nhave: %q\nwant: %q", test.op, test.src, test.to, have, test.want)
+		}
+	}
+}
+
+// runFix runs the op checker over the src file and returns
+// the fixes for all candidates that don't use the to variant.
+func runFix(t *testing.T, opKey string, pedantic bool, src string, to int) []string {
+	t.Helper()
+
+	var ctxt context
+	ctxt.flags.pedantic = pedantic
+	_ = ctxt.initCheckers()
+
+	ctxt.fset = token.NewFileSet()
+	f, err := parser.ParseFile(ctxt.fset, "p.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ctxt.info = &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: importer.Default()}
+	if _, err := conf.Check("p", ctxt.fset, []*ast.File{f}, ctxt.info); err != nil {
+		t.Fatalf("typecheck: %v", err)
+	}
+
+	var c checker
+	for _, c2 := range ctxt.checkers {
+		if c2.Operation().key() == opKey {
+			c = c2
+		}
+	}
+	if c == nil {
+		t.Fatalf("unknown %s operation", opKey)
+	}
+	toVariant := c.Operation().variants[to-1]
+
+	var fixes []string
+	ctxt.markHook = func(n ast.Node, v *opVariant) {
+		if ctxt.mismatch {
+			v = v.against(toVariant)
+		}
+		if v.op != c.Operation() || v == toVariant || ctxt.quiet {
+			return
+		}
+		edit, ok := ctxt.suggestFix(c, n, toVariant)
+		if !ok {
+			fixes = append(fixes, "")
+			return
+		}
+		fixes = append(fixes, edit.newText)
+	}
+	ctxt.walker.Walk(f)
+	return fixes
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"go/ast"
+	"regexp"
+)
+
+// generatedFileCommentRE matches the standard "generated code" marker.
+// See https://golang.org/s/generatedcode for the convention details.
+var generatedFileCommentRE = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// Generated files handling modes (see -include-generated flag).
+const (
+	generatedSkip   = "skip"
+	generatedCount  = "count"
+	generatedReport = "report"
+)
+
+// isGeneratedFile reports whether f is a generated file.
+//
+// A file is considered generated if any line comment that precedes
+// the package clause matches the Go convention marker.
+// If extraRE is not nil, it is matched against the text of
+// every comment group that precedes the package clause as well.
+func isGeneratedFile(f *ast.File, extraRE *regexp.Regexp) bool {
+	for _, group := range f.Comments {
+		if group.Pos() >= f.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if generatedFileCommentRE.MatchString(comment.Text) {
+				return true
+			}
+		}
+		if extraRE != nil && extraRE.MatchString(group.Text()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"regexp"
+	"testing"
+)
+
+func TestIsGeneratedFile(t *testing.T) {
+	tests := []struct {
+		src       string
+		extra     string
+		generated bool
+	}{
+		{"// Code generated by stringer. DO NOT EDIT.\n\npackage p", "", true},
+		{"//go:build linux\n\n// Code generated by protoc. DO NOT EDIT.\n\npackage p", "", true},
+		{"// Copyright 2018 Foo.\n// License: MIT.\n\n// Code generated by x. DO NOT EDIT.\npackage p", "", true},
+		{"/* Code generated by x. DO NOT EDIT. */\npackage p", "", false},
+		{"// Code generated by x. DO NOT EDIT\npackage p", "", false},
+		{"// Package p is not generated.\npackage p", "", false},
+		{"package p\n\n// Code generated by x. DO NOT EDIT.\n", "", false},
+		{"// Autogenerated by mockery.\npackage p", "", false},
+		{"// Autogenerated by mockery.\npackage p", `(?i)autogenerated`, true},
+		{"package p\n\n// Autogenerated by mockery.\n", `(?i)autogenerated`, false},
+	}
+
+	for _, test := range tests {
+		fset := token.NewFileSet()
+		f, err := parser.ParseFile(fset, "test.go", test.src, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("parse %q: %v", test.src, err)
+		}
+		var extraRE *regexp.Regexp
+		if test.extra != "" {
+			extraRE = regexp.MustCompile(test.extra)
+		}
+		have := isGeneratedFile(f, extraRE)
+		if have != test.generated {
+			t.Errorf("isGeneratedFile(%q, %q): have %v, want %v",
+				test.src, test.extra, have, test.generated)
+		}
+	}
+}
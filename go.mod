@@ -6,12 +6,11 @@ require (
 	github.com/go-toolsmith/astinfo v0.0.0-20180906194353-9809ff7efb21
 	github.com/go-toolsmith/pkgload v1.0.0
 	github.com/go-toolsmith/typep v1.0.0
-	github.com/kisielk/gotool v1.0.0
+	golang.org/x/mod v0.12.0
 	golang.org/x/tools v0.13.0
 )
 
 require (
-	golang.org/x/mod v0.12.0 // indirect
 	golang.org/x/sync v0.7.0 // indirect
 	golang.org/x/sys v0.12.0 // indirect
 )
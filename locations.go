@@ -4,27 +4,78 @@ import (
 	"go/token"
 )
 
-// TODO(Quasilyte): optimized implementation.
+// location is a packed source code position.
+//
+// Bits layout (from the highest to the lowest):
+//
+//	24 bits - file index inside the locationMap
+//	24 bits - line
+//	16 bits - column
+//
+// Lines and columns that don't fit are saturated to the max value.
+type location uint64
+
+const (
+	locationFileBits   = 24
+	locationLineBits   = 24
+	locationColumnBits = 16
+
+	maxLocationFile   = 1<<locationFileBits - 1
+	maxLocationLine   = 1<<locationLineBits - 1
+	maxLocationColumn = 1<<locationColumnBits - 1
+)
+
+// locationMap is an interned file names table that is used
+// to pack and unpack source code positions.
+//
+// It keeps only one copy of every file name, so the memory
+// consumption depends on the number of files, but not
+// on the number of positions that are stored.
 type locationMap struct {
-	m    map[token.Position]int
-	keys []token.Position
+	files   []string
+	fileIDs map[string]uint32
 }
 
 func newLocationMap() *locationMap {
-	return &locationMap{m: make(map[token.Position]int)}
+	return &locationMap{fileIDs: make(map[string]uint32)}
+}
+
+// Insert returns a packed location for the given position.
+func (locs *locationMap) Insert(filename string, line, column int) location {
+	fileID, ok := locs.fileIDs[filename]
+	if !ok {
+		if len(locs.files) > maxLocationFile {
+			panic("too many files to track")
+		}
+		fileID = uint32(len(locs.files))
+		locs.files = append(locs.files, filename)
+		locs.fileIDs[filename] = fileID
+	}
+	return location(uint64(fileID)<<(locationLineBits+locationColumnBits) |
+		uint64(saturate(line, maxLocationLine))<<locationColumnBits |
+		uint64(saturate(column, maxLocationColumn)))
 }
 
-func (locs *locationMap) Insert(filename string, line, column int) int {
-	key := token.Position{Filename: filename, Line: line, Column: column}
-	if id, ok := locs.m[key]; ok {
-		return id
+// Get unpacks the location into a full position.
+func (locs *locationMap) Get(loc location) token.Position {
+	return token.Position{
+		Filename: locs.files[loc.fileID()],
+		Line:     int(loc>>locationColumnBits) & maxLocationLine,
+		Column:   int(loc) & maxLocationColumn,
 	}
-	id := len(locs.m)
-	locs.keys = append(locs.keys, key)
-	locs.m[key] = id
-	return id
 }
 
-func (locs *locationMap) Get(id int) token.Position {
-	return locs.keys[id]
+func (loc location) fileID() int {
+	return int(loc >> (locationLineBits + locationColumnBits))
+}
+
+func saturate(x, max int) int {
+	switch {
+	case x < 0:
+		return 0
+	case x > max:
+		return max
+	default:
+		return x
+	}
 }
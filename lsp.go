@@ -0,0 +1,567 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// runLSP implements the "lsp" subcommand.
+//
+// It runs a language server that speaks LSP over stdin/stdout.
+// The project-wide variant counts are computed once, after the initialization;
+// every opened or changed file is then analyzed against this majority.
+//
+// Usage: go-consistent lsp [flags] [targets]
+//
+// If targets are not specified, the entire workspace root is checked.
+func runLSP(args []string) error {
+	var ctxt context
+	fs := flag.NewFlagSet("lsp", flag.ExitOnError)
+	if err := ctxt.parseArgs(fs, args); err != nil {
+		return err
+	}
+	s := newLSPServer(&ctxt, os.Stdin, os.Stdout)
+	return s.serve()
+}
+
+type lspServer struct {
+	ctxt *context
+
+	in  *bufio.Reader
+	out io.Writer
+
+	// root is a workspace root directory.
+	root string
+
+	// ready is set after the project-wide counts are computed.
+	ready bool
+
+	shutdown bool
+
+	docs map[string]*lspDocument
+
+	// checkerOf maps operations to the checkers that implement them.
+	checkerOf map[*operation]checker
+}
+
+// lspDocument is an opened text document.
+type lspDocument struct {
+	filename string
+	text     []byte
+
+	diagnostics []lspStoredDiagnostic
+}
+
+// lspStoredDiagnostic is a published diagnostic along with its quick fix.
+type lspStoredDiagnostic struct {
+	diagnostic lspDiagnostic
+	fix        *lspTextEdit
+}
+
+func newLSPServer(ctxt *context, in io.Reader, out io.Writer) *lspServer {
+	ctxt.allowErrors = true
+	return &lspServer{
+		ctxt: ctxt,
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*lspDocument),
+	}
+}
+
+func (s *lspServer) serve() error {
+	for {
+		msg, err := s.readMessage()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		result, err := s.handle(msg)
+		if msg.ID == nil {
+			if err != nil {
+				log.Printf("lsp: %s: %v", msg.Method, err)
+			}
+			continue // Notification, no response is expected
+		}
+		if err := s.reply(msg.ID, result, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *lspServer) handle(msg *lspMessage) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		var params lspInitializeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params)
+	case "initialized":
+		s.analyzeProject()
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params lspDidOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		s.updateDocument(params.TextDocument.URI, []byte(params.TextDocument.Text))
+		return nil, nil
+	case "textDocument/didChange":
+		var params lspDidChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// We use the full sync mode, so the last change holds the entire text.
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		s.updateDocument(params.TextDocument.URI, []byte(text))
+		return nil, nil
+	case "textDocument/didClose":
+		var params lspDidCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.publishDiagnostics(params.TextDocument.URI, nil)
+	case "textDocument/codeAction":
+		var params lspCodeActionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.codeActions(params), nil
+
+	default:
+		if msg.ID == nil {
+			return nil, nil // Ignore unknown notifications
+		}
+		return nil, &lspError{Code: lspMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+}
+
+func (s *lspServer) initialize(params lspInitializeParams) (interface{}, error) {
+	switch {
+	case params.RootURI != "":
+		s.root = uriToPath(params.RootURI)
+	case params.RootPath != "":
+		s.root = params.RootPath
+	default:
+		s.root = s.ctxt.workDir
+	}
+
+	result := map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				"change":    1, // Full
+			},
+			"codeActionProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "go-consistent"},
+	}
+	return result, nil
+}
+
+// analyzeProject computes the project-wide variant counts and suggestions.
+func (s *lspServer) analyzeProject() {
+	ctxt := s.ctxt
+	if s.root != "" {
+		ctxt.workDir = s.root
+	}
+	if len(ctxt.flags.targets) == 0 {
+		ctxt.flags.targets = []string{filepath.Join(ctxt.workDir, "...")}
+	}
+
+	steps := []struct {
+		name string
+		fn   func() error
+	}{
+		{"resolve targets", ctxt.resolveTargets},
+		{"init checkers", ctxt.initCheckers},
+		{"collect candidates", ctxt.collectAllCandidates},
+		{"assign suggestions", ctxt.assignSuggestions},
+	}
+	for _, step := range steps {
+		if err := step.fn(); err != nil {
+			log.Printf("lsp: %s: %v", step.name, err)
+			return
+		}
+	}
+	// Only the counts are needed from now on.
+	ctxt.candidates.Reset()
+
+	s.checkerOf = make(map[*operation]checker)
+	for _, c := range ctxt.checkers {
+		s.checkerOf[c.Operation()] = c
+	}
+	s.ready = true
+
+	for uri := range s.docs {
+		s.analyzeDocument(uri)
+	}
+}
+
+func (s *lspServer) updateDocument(uri string, text []byte) {
+	doc := s.docs[uri]
+	if doc == nil {
+		doc = &lspDocument{filename: uriToPath(uri)}
+		s.docs[uri] = doc
+	}
+	doc.text = text
+	if s.ready {
+		s.analyzeDocument(uri)
+	}
+}
+
+// analyzeDocument re-analyzes the document and publishes its diagnostics.
+//
+// The project-wide counts are not affected by the document analysis,
+// the diagnostics are reported against the project majority.
+func (s *lspServer) analyzeDocument(uri string) {
+	ctxt := s.ctxt
+	doc := s.docs[uri]
+	if doc == nil || !strings.HasSuffix(doc.filename, ".go") {
+		return
+	}
+
+	// Save the counts, so they can be restored after the analysis.
+	counts := ctxt.variantCounts()
+	defer func() {
+		counts.restore()
+		ctxt.candidates.Reset()
+		ctxt.markHook = nil
+		ctxt.onlyFile = ""
+	}()
+
+	ctxt.overlay = make(map[string][]byte, len(s.docs))
+	for _, d := range s.docs {
+		ctxt.overlay[d.filename] = d.text
+	}
+	ctxt.seenFiles = make(map[string]struct{})
+	ctxt.onlyFile = doc.filename
+	lines := splitLines(doc.text)
+	var diagnostics []lspStoredDiagnostic
+	ctxt.markHook = func(n ast.Node, v *opVariant) {
+		suggested := v.op.suggestedFor(ctxt.pool)
+		if ctxt.mismatch {
+			v = v.against(suggested)
+		}
+		if suggested == v || ctxt.quiet {
+			return
+		}
+		d := lspStoredDiagnostic{
+			diagnostic: lspDiagnostic{
+				Range: lspRange{
+					Start: lspPositionOf(lines, ctxt.fset.Position(n.Pos())),
+					End:   lspPositionOf(lines, ctxt.fset.Position(n.End())),
+				},
+				Severity: lspSeverities[v.op.severity],
+				Code:     v.op.key(),
+				Source:   "go-consistent",
+				Message:  v.op.name + ": " + suggested.warning,
+			},
+		}
+		if edit, ok := ctxt.suggestFix(s.checkerOf[v.op], n, suggested); ok {
+			d.fix = &lspTextEdit{
+				Range: lspRange{
+					Start: lspPositionOf(lines, edit.pos),
+					End:   lspPositionOf(lines, edit.end),
+				},
+				NewText: edit.newText,
+			}
+		}
+		diagnostics = append(diagnostics, d)
+	}
+
+	p := targetPath{path: "file=" + doc.filename}
+	if root := ctxt.findModuleRoot(filepath.Dir(doc.filename)); root != "" {
+		p.dir = root
+	}
+	if err := ctxt.collectPathCandidates(p, buildConfig{}); err != nil {
+		log.Printf("lsp: analyze %s: %v", doc.filename, err)
+		return
+	}
+
+	doc.diagnostics = diagnostics
+	published := make([]lspDiagnostic, 0, len(diagnostics))
+	for _, d := range diagnostics {
+		published = append(published, d.diagnostic)
+	}
+	if err := s.publishDiagnostics(uri, published); err != nil {
+		log.Printf("lsp: publish diagnostics: %v", err)
+	}
+}
+
+func (s *lspServer) codeActions(params lspCodeActionParams) []lspCodeAction {
+	actions := []lspCodeAction{}
+	doc := s.docs[params.TextDocument.URI]
+	if doc == nil {
+		return actions
+	}
+	for _, d := range doc.diagnostics {
+		if d.fix == nil || !d.diagnostic.Range.overlaps(params.Range) {
+			continue
+		}
+		actions = append(actions, lspCodeAction{
+			Title:       "go-consistent: " + d.diagnostic.Message,
+			Kind:        "quickfix",
+			Diagnostics: []lspDiagnostic{d.diagnostic},
+			Edit: lspWorkspaceEdit{
+				Changes: map[string][]lspTextEdit{
+					params.TextDocument.URI: {*d.fix},
+				},
+			},
+		})
+	}
+	return actions
+}
+
+func (s *lspServer) publishDiagnostics(uri string, diagnostics []lspDiagnostic) error {
+	if diagnostics == nil {
+		diagnostics = []lspDiagnostic{}
+	}
+	return s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diagnostics,
+	})
+}
+
+func (s *lspServer) readMessage() (*lspMessage, error) {
+	contentLength := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break // End of headers
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("bad Content-Length: %w", err)
+			}
+			contentLength = n
+		}
+	}
+	if contentLength < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	data := make([]byte, contentLength)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *lspServer) reply(id *json.RawMessage, result interface{}, err error) error {
+	if err != nil {
+		var lspErr *lspError
+		if !errors.As(err, &lspErr) {
+			lspErr = &lspError{Code: lspInternalError, Message: err.Error()}
+		}
+		return s.write(struct {
+			JSONRPC string           `json:"jsonrpc"`
+			ID      *json.RawMessage `json:"id"`
+			Error   *lspError        `json:"error"`
+		}{"2.0", id, lspErr})
+	}
+	return s.write(struct {
+		JSONRPC string           `json:"jsonrpc"`
+		ID      *json.RawMessage `json:"id"`
+		Result  interface{}      `json:"result"`
+	}{"2.0", id, result})
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	return s.write(struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params"`
+	}{"2.0", method, params})
+}
+
+func (s *lspServer) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+// lspPositionOf converts a token position into the LSP position.
+// LSP uses zero-based lines and UTF-16 based character offsets.
+func lspPositionOf(lines []string, pos token.Position) lspPosition {
+	line := pos.Line - 1
+	if line < 0 || line >= len(lines) {
+		return lspPosition{Line: line}
+	}
+	text := lines[line]
+	offset := pos.Column - 1
+	if offset > len(text) {
+		offset = len(text)
+	}
+	character := 0
+	for _, r := range text[:offset] {
+		if r >= 0x10000 && r != utf8.RuneError {
+			character += 2 // Surrogate pair
+		} else {
+			character++
+		}
+	}
+	return lspPosition{Line: line, Character: character}
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// LSP protocol types.
+// Only the fields that are used by the server are declared.
+
+const (
+	lspMethodNotFound = -32601
+	lspInternalError  = -32603
+
+	lspSeverityError       = 1
+	lspSeverityWarning     = 2
+	lspSeverityInformation = 3
+)
+
+// lspSeverities maps the operation severities to the LSP ones.
+var lspSeverities = map[string]int{
+	severityError:   lspSeverityError,
+	severityWarning: lspSeverityWarning,
+	severityInfo:    lspSeverityInformation,
+}
+
+type lspMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string { return e.Message }
+
+type lspInitializeParams struct {
+	RootURI  string `json:"rootUri"`
+	RootPath string `json:"rootPath"`
+}
+
+type lspTextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type lspDidOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type lspDidChangeParams struct {
+	TextDocument   lspTextDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type lspDidCloseParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+}
+
+type lspCodeActionParams struct {
+	TextDocument lspTextDocumentIdentifier `json:"textDocument"`
+	Range        lspRange                  `json:"range"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+func (pos lspPosition) less(other lspPosition) bool {
+	if pos.Line != other.Line {
+		return pos.Line < other.Line
+	}
+	return pos.Character < other.Character
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+// overlaps reports whether r and other ranges have any common positions.
+// Ranges are treated as closed intervals, so an empty range
+// (a cursor position) can still overlap with the other one.
+func (r lspRange) overlaps(other lspRange) bool {
+	return !r.End.less(other.Start) && !other.End.less(r.Start)
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspWorkspaceEdit struct {
+	Changes map[string][]lspTextEdit `json:"changes"`
+}
+
+type lspCodeAction struct {
+	Title       string           `json:"title"`
+	Kind        string           `json:"kind"`
+	Diagnostics []lspDiagnostic  `json:"diagnostics"`
+	Edit        lspWorkspaceEdit `json:"edit"`
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"go/token"
+)
+
+func TestLSPPositionOf(t *testing.T) {
+	lines := []string{
+		"package p",
+		`var s = "héllo😀"; var x = 1`,
+	}
+	tests := []struct {
+		pos  token.Position
+		want lspPosition
+	}{
+		{token.Position{Line: 1, Column: 1}, lspPosition{Line: 0, Character: 0}},
+		{token.Position{Line: 1, Column: 9}, lspPosition{Line: 0, Character: 8}},
+		// "é" is 2 bytes and 1 UTF-16 unit, "😀" is 4 bytes and 2 UTF-16 units.
+		{token.Position{Line: 2, Column: 25}, lspPosition{Line: 1, Character: 21}},
+	}
+	for _, test := range tests {
+		have := lspPositionOf(lines, test.pos)
+		if have != test.want {
+			t.Errorf("lspPositionOf(%v): have %+v, want %+v", test.pos, have, test.want)
+		}
+	}
+}
+
+func TestLSPRangeOverlaps(t *testing.T) {
+	r := lspRange{Start: lspPosition{Line: 2, Character: 4}, End: lspPosition{Line: 2, Character: 10}}
+	tests := []struct {
+		other lspRange
+		want  bool
+	}{
+		{lspRange{Start: lspPosition{Line: 2, Character: 4}, End: lspPosition{Line: 2, Character: 4}}, true},
+		{lspRange{Start: lspPosition{Line: 2, Character: 10}, End: lspPosition{Line: 2, Character: 10}}, true},
+		{lspRange{Start: lspPosition{Line: 0, Character: 0}, End: lspPosition{Line: 5, Character: 0}}, true},
+		{lspRange{Start: lspPosition{Line: 2, Character: 11}, End: lspPosition{Line: 3, Character: 0}}, false},
+		{lspRange{Start: lspPosition{Line: 1, Character: 0}, End: lspPosition{Line: 2, Character: 3}}, false},
+	}
+	for _, test := range tests {
+		if have := r.overlaps(test.other); have != test.want {
+			t.Errorf("overlaps(%+v): have %v, want %v", test.other, have, test.want)
+		}
+	}
+}
+
+func TestLSPServer(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, src string) string {
+		t.Helper()
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	writeFile("go.mod", "module example.com/p\n")
+	writeFile("a.go", "package p\n\nvar (\n\t_ = make(map[int]int)\n\t_ = make(map[int]int)\n)\n")
+	filename := writeFile("b.go", "package p\n")
+
+	var ctxt context
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	if err := ctxt.parseArgs(fs, []string{"-cache=off"}); err != nil {
+		t.Fatal(err)
+	}
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	s := newLSPServer(&ctxt, serverIn, serverOut)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.serve()
+		serverOut.Close()
+	}()
+
+	messages := make(chan map[string]json.RawMessage)
+	go func() {
+		defer close(messages)
+		r := bufio.NewReader(clientIn)
+		for {
+			header, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+			if err != nil {
+				t.Errorf("bad header: %q", header)
+				return
+			}
+			if _, err := r.ReadString('\n'); err != nil {
+				return
+			}
+			data := make([]byte, n)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			var msg map[string]json.RawMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Errorf("unmarshal %s: %v", data, err)
+				return
+			}
+			messages <- msg
+		}
+	}()
+
+	id := 0
+	send := func(method string, params interface{}, request bool) {
+		t.Helper()
+		msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+		if request {
+			id++
+			msg["id"] = id
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fmt.Fprintf(clientOut, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+			t.Fatalf("send %s: %v", method, err)
+		}
+	}
+	receive := func(key string, dst interface{}) {
+		t.Helper()
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				t.Fatalf("connection is closed, want %s", key)
+			}
+			if err := json.Unmarshal(msg[key], dst); err != nil {
+				t.Fatalf("unmarshal %s: %v: %v", key, err, msg)
+			}
+		case <-time.After(time.Minute):
+			t.Fatalf("timeout waiting for %s", key)
+		}
+	}
+
+	rootURI := (&url.URL{Scheme: "file", Path: filepath.ToSlash(dir)}).String()
+	uri := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filename)}).String()
+
+	send("initialize", map[string]interface{}{"rootUri": rootURI}, true)
+	var initResult struct {
+		Capabilities struct {
+			CodeActionProvider bool `json:"codeActionProvider"`
+		} `json:"capabilities"`
+	}
+	receive("result", &initResult)
+	if !initResult.Capabilities.CodeActionProvider {
+		t.Errorf("code actions are not advertised")
+	}
+	send("initialized", map[string]interface{}{}, false)
+
+	// The opened document is inconsistent with the a.go on the disk.
+	text := "package p\n\nvar _ = map[int]int{}\n"
+	send("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "go", "version": 1, "text": text},
+	}, false)
+	var published struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}
+	receive("params", &published)
+	wantDiagnostic := lspDiagnostic{
+		Range: lspRange{
+			Start: lspPosition{Line: 2, Character: 8},
+			End:   lspPosition{Line: 2, Character: 21},
+		},
+		Severity: lspSeverityError,
+		Code:     "empty-map",
+		Source:   "go-consistent",
+		Message:  "empty map: use make(map[K]V)",
+	}
+	if published.URI != uri || len(published.Diagnostics) != 1 || published.Diagnostics[0] != wantDiagnostic {
+		t.Fatalf("unexpected diagnostics:\nhave: %+v\nwant: %+v", published, wantDiagnostic)
+	}
+
+	send("textDocument/codeAction", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"range":        lspRange{Start: lspPosition{Line: 2, Character: 10}, End: lspPosition{Line: 2, Character: 10}},
+		"context":      map[string]interface{}{"diagnostics": published.Diagnostics},
+	}, true)
+	var actions []lspCodeAction
+	receive("result", &actions)
+	if len(actions) != 1 {
+		t.Fatalf("have %d code actions, want 1", len(actions))
+	}
+	wantEdit := lspTextEdit{Range: wantDiagnostic.Range, NewText: "make(map[int]int)"}
+	if edits := actions[0].Edit.Changes[uri]; len(edits) != 1 || edits[0] != wantEdit {
+		t.Errorf("unexpected code action edits:\nhave: %+v\nwant: %+v", edits, wantEdit)
+	}
+
+	send("shutdown", nil, true)
+	var shutdownResult interface{}
+	receive("result", &shutdownResult)
+	send("exit", nil, false)
+	clientOut.Close()
+	if err := <-serveErr; err != nil {
+		t.Errorf("serve: %v", err)
+	}
+}
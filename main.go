@@ -8,21 +8,40 @@ import (
 	"go/build"
 	"go/token"
 	"go/types"
+	"io"
 	"log"
+	"math"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
-	"github.com/go-toolsmith/astinfo"
 	"github.com/go-toolsmith/pkgload"
-	"github.com/kisielk/gotool"
 	"golang.org/x/tools/go/packages"
 )
 
-var generatedFileCommentRE = regexp.MustCompile("Code generated .* DO NOT EDIT.")
+// subcommands are invoked as `go-consistent <name> [args...]`.
+// Without a subcommand, the targets are checked and the warnings are printed.
+var subcommands = map[string]func(args []string) error{
+	"lsp":     runLSP,
+	"cache":   runCache,
+	"compare": runCompare,
+	"plan":    runPlan,
+}
 
 func main() {
 	log.SetFlags(0)
+
+	if len(os.Args) > 1 {
+		if cmd := subcommands[os.Args[1]]; cmd != nil {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatalf("%s: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
 	var ctxt context
 
 	steps := []struct {
@@ -35,12 +54,20 @@ func main() {
 		{"collect candidates", ctxt.collectAllCandidates},
 		{"assign suggestions", ctxt.assignSuggestions},
 		{"print warnings", ctxt.printWarnings},
+		{"watch", ctxt.watch},
 	}
 
 	for _, step := range steps {
+		start := time.Now()
 		if err := step.fn(); err != nil {
+			ctxt.finish()
 			log.Fatalf("%s: %v", step.name, err)
 		}
+		ctxt.timings.addStep(step.name, time.Since(start))
+	}
+	ctxt.finish()
+	if code := ctxt.exitCode(); code != 0 {
+		os.Exit(code)
 	}
 }
 
@@ -55,57 +82,361 @@ type context struct {
 		shorterErrLocation bool
 		noTypes            bool
 
-		targets []string
-		exclude string
+		targets          []string
+		exclude          string
+		generatedPattern string
+		includeGenerated string
+		skipFiles        string
+		skipDirs         string
+		tests            string
+		scope            string
+		modfile          string
+		tags             string
+		buildMatrix      string
+		spillThreshold   int
+		format           string
+		output           string
+		evidence         int
+		stdinFilename    string
+		staged           bool
+		watch            bool
+		cache            string
+		cpuProfile       string
+		memProfile       string
+		timings          bool
+		exitCode         int
+		maxWarnings      int
+		failOn           string
+		severity         string
+		showSeverity     bool
+		codeowners       string
+		groupBy          string
 	}
 
 	workDir string
 
-	paths []string
+	paths []targetPath
+
+	// buildConfigs is a list of platforms to analyze every path for.
+	buildConfigs []buildConfig
+
+	// seenFiles is a set of already analyzed files.
+	// Files can be reached several times, for example, when targets overlap
+	// or when the file is shared between several build configs;
+	// every file is analyzed only once, so it votes and reports exactly once.
+	seenFiles map[string]struct{}
+
+	// seenSkips is a number of files that were skipped,
+	// because they were already analyzed.
+	seenSkips int
+
+	// moduleDirs maps module root directories to the module paths.
+	moduleDirs map[string]string
+
+	// workspaceModules is a set of go.work module dirs.
+	// nil if the workspace mode is not active.
+	workspaceModules map[string]bool
 
 	locs *locationMap
 
-	fset    *token.FileSet
-	info    *types.Info
-	astinfo astinfo.Info
+	// generatedRE is a compiled -generated-pattern; nil if the flag is empty.
+	generatedRE *regexp.Regexp
+
+	// skipFiles and skipDirs are parsed -skip-files and -skip-dirs lists.
+	skipFiles globList
+	skipDirs  globList
+
+	// pools is a list of voting pool names, indexed by the pool ID.
+	// See pools.go for details.
+	pools   []string
+	poolIDs map[string]int
+
+	// pool is an ID of the voting pool for the file being analyzed.
+	pool int
+
+	// overlay maps file names to their contents that should
+	// be used instead of the file system contents.
+	overlay map[string][]byte
+
+	// stdinFile is an absolute path of the -stdin-filename file.
+	// Only this file is reported, the others only participate in the voting.
+	stdinFile string
+
+	// codeowners is a parsed -codeowners file; nil if the flag is not set.
+	codeowners *codeOwners
+
+	// staged is the git index state; nil if -staged flag is not set.
+	staged *stagedChanges
+
+	// onlyFile, if not empty, restricts the analysis to the single file.
+	// Other files are still loaded, so the types information is complete.
+	onlyFile string
+
+	// allowErrors makes the build errors non-fatal; the packages
+	// are analyzed with whatever information was available.
+	allowErrors bool
+
+	// markHook, if not nil, is called for every marked candidate.
+	markHook func(n ast.Node, v *opVariant)
+
+	// onLoad, if not nil, is called for every loaded packages list.
+	onLoad func(pkgs []*packages.Package)
+
+	// onSeen, if not nil, is called for every file marked as seen.
+	onSeen func(key string)
+
+	// cacheStats are the -cache usage statistics.
+	cacheStats struct {
+		hits   int
+		misses int
+	}
+
+	// timings is a -timings report data; nil if the flag is not set.
+	timings *timings
+
+	// cpuProfile is an opened -cpuprofile file.
+	cpuProfile *os.File
+
+	// warnings is a number of reported warnings.
+	warnings int
+
+	// failures is a number of reported warnings that
+	// are considered failures, see -fail-on.
+	failures int
+
+	// failOn is a set of operations that fail the run.
+	// nil means that all error severity operations do.
+	failOn map[*operation]bool
+
+	// watched is a list of paths tracked in the -watch mode.
+	watched []*watchedPath
+
+	// quiet is set for files whose candidates only participate in
+	// the voting and never produce warnings.
+	quiet bool
+
+	// mismatch is set while the candidates that don't vote
+	// are marked, see context.markMismatch.
+	mismatch bool
+
+	fset *token.FileSet
+	info *types.Info
 
 	checkers []checker
+	walker   *fileWalker
 
-	candidates []candidate
+	candidates candidateList
 }
 
 func (ctxt *context) parseFlags() error {
-	flag.BoolVar(&ctxt.flags.pedantic, "pedantic", false,
+	flag.StringVar(&ctxt.flags.stdinFilename, "stdin-filename", "",
+		`read the specified file contents from the stdin; only this file is reported, the others are used for voting`)
+	flag.BoolVar(&ctxt.flags.staged, "staged", false,
+		`check the files as they are staged in the git index; only the staged lines are reported, the whole project is used for voting`)
+	flag.StringVar(&ctxt.flags.cpuProfile, "cpuprofile", "",
+		`write a CPU profile to the specified file`)
+	flag.StringVar(&ctxt.flags.memProfile, "memprofile", "",
+		`write a memory profile to the specified file before the exit`)
+	flag.BoolVar(&ctxt.flags.timings, "timings", false,
+		`print the time spent on every step, package and checker`)
+	if err := ctxt.parseArgs(flag.CommandLine, os.Args[1:]); err != nil {
+		return err
+	}
+	if ctxt.flags.timings {
+		ctxt.timings = &timings{}
+	}
+	if err := ctxt.startProfiling(); err != nil {
+		return fmt.Errorf("-cpuprofile: %w", err)
+	}
+	if ctxt.flags.stdinFilename != "" {
+		if err := ctxt.setStdinFile(ctxt.flags.stdinFilename, os.Stdin); err != nil {
+			return fmt.Errorf("-stdin-filename: %w", err)
+		}
+		if len(ctxt.flags.targets) == 0 {
+			// Check the file package, so it has something to vote with.
+			ctxt.flags.targets = []string{filepath.Dir(ctxt.stdinFile)}
+		}
+	}
+	if ctxt.flags.staged {
+		if ctxt.flags.stdinFilename != "" || ctxt.flags.watch {
+			return errors.New("-staged can't be combined with -stdin-filename or -watch")
+		}
+		if err := ctxt.loadStaged(); err != nil {
+			return fmt.Errorf("-staged: %w", err)
+		}
+	}
+	if len(ctxt.flags.targets) == 0 {
+		return errors.New("not enough positional args (empty targets list)")
+	}
+	return nil
+}
+
+// parseArgs registers all analysis flags inside fs and parses the args.
+// Subcommands can register their own flags in fs before calling it.
+func (ctxt *context) parseArgs(fs *flag.FlagSet, args []string) error {
+	fs.BoolVar(&ctxt.flags.pedantic, "pedantic", false,
 		`makes several diagnostics more pedantic and comprehensive`)
-	flag.BoolVar(&ctxt.flags.verbose, "v", false,
+	fs.BoolVar(&ctxt.flags.verbose, "v", false,
 		`turn on detailed program execution info printing`)
-	flag.BoolVar(&ctxt.flags.shorterErrLocation, `shorterErrLocation`, true,
+	fs.BoolVar(&ctxt.flags.shorterErrLocation, `shorterErrLocation`, true,
 		`whether to replace error location prefix with $GOROOT and $GOPATH`)
-	flag.BoolVar(&ctxt.flags.noTypes, "syntax-only", false,
+	fs.BoolVar(&ctxt.flags.noTypes, "syntax-only", false,
 		`disable the typechecking; some checkers can't work without types information`)
-	flag.StringVar(&ctxt.flags.exclude, "exclude", `^unsafe$|^builtin$`,
+	fs.StringVar(&ctxt.flags.exclude, "exclude", `^unsafe$|^builtin$`,
 		`import path excluding regexp`)
+	fs.StringVar(&ctxt.flags.generatedPattern, "generated-pattern", "",
+		`additional regexp to detect generated files; matched against comments that precede the package clause`)
+	fs.StringVar(&ctxt.flags.includeGenerated, "include-generated", generatedSkip,
+		`how to treat generated files: count (vote, but never warn), report (vote and warn) or skip`)
+	fs.StringVar(&ctxt.flags.skipFiles, "skip-files", "",
+		`comma-separated list of file glob patterns to skip, like "*_string.go,mock_*.go"`)
+	fs.StringVar(&ctxt.flags.skipDirs, "skip-dirs", "",
+		`comma-separated list of directory glob patterns to skip, like "testdata,vendor,mocks"`)
+	fs.StringVar(&ctxt.flags.tests, "tests", testsInclude,
+		`how to treat test files: include (vote together with the package code), exclude or separate (vote in their own pool)`)
+	fs.StringVar(&ctxt.flags.scope, "scope", scopeProject,
+		`voting scope: project (all targets vote together) or module (every module votes separately)`)
+	fs.StringVar(&ctxt.flags.modfile, "modfile", "",
+		`an alternate go.mod file for the module in the working directory, like in the go command`)
+	fs.StringVar(&ctxt.flags.tags, "tags", "",
+		`a comma-separated list of build tags, like in the go command`)
+	fs.StringVar(&ctxt.flags.buildMatrix, "build-matrix", "",
+		`a comma-separated list of GOOS/GOARCH pairs to analyze, like "linux/amd64,windows/amd64,js/wasm"`)
+	fs.StringVar(&ctxt.flags.format, "format", "text",
+		`output format: text, json, sarif, checkstyle, junit, github, gitlab, markdown or html`)
+	fs.IntVar(&ctxt.flags.evidence, "evidence", 0,
+		`print up to N example locations of the suggested variant for every reported operation`)
+	fs.StringVar(&ctxt.flags.output, "o", "",
+		`write the report to the specified file instead of the stdout`)
+	fs.BoolVar(&ctxt.flags.watch, "watch", false,
+		`keep running and re-check the changed packages whenever their files are modified`)
+	fs.StringVar(&ctxt.flags.cache, "cache", cacheOn,
+		`analysis results cache directory; "on" means the default user cache directory, "off" disables the cache`)
+	fs.IntVar(&ctxt.flags.exitCode, "exit-code", 1,
+		`exit code to use when the check fails`)
+	fs.IntVar(&ctxt.flags.maxWarnings, "max-warnings", 0,
+		`max number of failing warnings that are tolerated`)
+	fs.StringVar(&ctxt.flags.failOn, "fail-on", "",
+		`comma-separated list of operations whose warnings fail the check, like "empty-map,hex-lit"; by default, only the error severity operations do`)
+	fs.StringVar(&ctxt.flags.severity, "severity", "",
+		`comma-separated list of operation severity overrides, like "hex-lit=warning,label-case=error"; severity is error, warning or info`)
+	fs.BoolVar(&ctxt.flags.showSeverity, "show-severity", false,
+		`print the warning severities in the text output`)
+	fs.StringVar(&ctxt.flags.codeowners, "codeowners", "",
+		`CODEOWNERS file to read the warnings owners from; GitHub and GitLab syntax is supported`)
+	fs.StringVar(&ctxt.flags.groupBy, "group-by", "",
+		`group the text and markdown reports warnings; the only supported value is "owner", it requires -codeowners`)
+	fs.IntVar(&ctxt.flags.spillThreshold, "spill-threshold", 0,
+		`max number of candidates kept in memory, the rest is spilled into a temporary file; 0 means no limit`)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
 
-	flag.Parse()
+	ctxt.flags.targets = fs.Args()
 
-	ctxt.flags.targets = flag.Args()
-	if len(ctxt.flags.targets) == 0 {
-		return errors.New("not enough positional args (empty targets list)")
+	switch ctxt.flags.includeGenerated {
+	case generatedSkip, generatedCount, generatedReport:
+		// OK.
+	default:
+		return fmt.Errorf("-include-generated: unexpected value %q", ctxt.flags.includeGenerated)
+	}
+	switch ctxt.flags.tests {
+	case testsInclude, testsExclude, testsSeparate:
+		// OK.
+	default:
+		return fmt.Errorf("-tests: unexpected value %q", ctxt.flags.tests)
+	}
+	if _, ok := reportFormats[ctxt.flags.format]; !ok {
+		return fmt.Errorf("-format: unexpected value %q", ctxt.flags.format)
+	}
+	if ctxt.flags.evidence > 0 && ctxt.flags.format != "text" {
+		return fmt.Errorf("-evidence: %s format doesn't support evidence", ctxt.flags.format)
+	}
+	switch ctxt.flags.scope {
+	case scopeProject, scopeModule:
+		// OK.
+	default:
+		return fmt.Errorf("-scope: unexpected value %q", ctxt.flags.scope)
+	}
+	if ctxt.flags.generatedPattern != "" {
+		re, err := regexp.Compile(ctxt.flags.generatedPattern)
+		if err != nil {
+			return fmt.Errorf("compiling -generated-pattern regexp: %w", err)
+		}
+		ctxt.generatedRE = re
+	}
+	buildConfigs, err := parseBuildMatrix(ctxt.flags.buildMatrix)
+	if err != nil {
+		return fmt.Errorf("-build-matrix: %w", err)
+	}
+	ctxt.buildConfigs = buildConfigs
+	skipFiles, err := parseGlobList(ctxt.flags.skipFiles)
+	if err != nil {
+		return fmt.Errorf("-skip-files: %w", err)
+	}
+	skipDirs, err := parseGlobList(ctxt.flags.skipDirs)
+	if err != nil {
+		return fmt.Errorf("-skip-dirs: %w", err)
+	}
+	ctxt.skipFiles = skipFiles
+	ctxt.skipDirs = skipDirs
+
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Printf("getwd: %v", err)
 	}
+	ctxt.workDir = wd
 
-	if ctxt.flags.shorterErrLocation {
-		wd, err := os.Getwd()
+	if ctxt.flags.codeowners != "" {
+		co, err := loadCodeOwners(ctxt.flags.codeowners, ctxt.repoRoot())
 		if err != nil {
-			log.Printf("getwd: %v", err)
+			return fmt.Errorf("-codeowners: %w", err)
 		}
-		ctxt.workDir = wd
+		ctxt.codeowners = co
+	}
+	switch ctxt.flags.groupBy {
+	case "":
+		// OK.
+	case groupByOwner:
+		if ctxt.codeowners == nil {
+			return errors.New("-group-by: owner grouping requires -codeowners")
+		}
+		if ctxt.flags.format != "text" && ctxt.flags.format != "markdown" {
+			return fmt.Errorf("-group-by: %s format doesn't support grouping", ctxt.flags.format)
+		}
+	default:
+		return fmt.Errorf("-group-by: unexpected value %q", ctxt.flags.groupBy)
 	}
 
 	return nil
 }
 
+// setStdinFile makes filename contents to be read from r
+// instead of the file system.
+func (ctxt *context) setStdinFile(filename string, r io.Reader) error {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if ctxt.overlay == nil {
+		ctxt.overlay = make(map[string][]byte)
+	}
+	ctxt.overlay[abs] = data
+	ctxt.stdinFile = abs
+	return nil
+}
+
 func (ctxt *context) resolveTargets() error {
-	ctxt.paths = gotool.ImportPaths(ctxt.flags.targets)
+	paths, err := ctxt.expandTargets(ctxt.flags.targets)
+	if err != nil {
+		return err
+	}
+	ctxt.paths = paths
 	if len(ctxt.paths) == 0 {
 		return errors.New("targets resolved to an empty import paths list")
 	}
@@ -115,10 +446,10 @@ func (ctxt *context) resolveTargets() error {
 	if err != nil {
 		return fmt.Errorf("compiling -exclude regexp: %w", err)
 	}
-	paths := ctxt.paths[:0]
-	for _, path := range ctxt.paths {
-		if !excludeRE.MatchString(path) {
-			paths = append(paths, path)
+	paths = ctxt.paths[:0]
+	for _, p := range ctxt.paths {
+		if !excludeRE.MatchString(p.path) {
+			paths = append(paths, p)
 		}
 	}
 	ctxt.paths = paths
@@ -149,7 +480,9 @@ func (ctxt *context) initCheckers() error {
 
 	hasTypes := !ctxt.flags.noTypes
 	variantID := 0
-	enabledCheckers := checkers[:0]
+	// checkers keeps all operations, so the flags can
+	// refer to the ones that are disabled.
+	enabledCheckers := make([]checker, 0, len(checkers))
 	for _, c := range checkers {
 		op := c.Operation()
 		if op.name == "" {
@@ -170,38 +503,154 @@ func (ctxt *context) initCheckers() error {
 		enabledCheckers = append(enabledCheckers, c)
 	}
 
+	if variantID > math.MaxUint16 {
+		panic("too many op variants")
+	}
+
+	if err := applySeverities(ctxt.flags.severity, checkers); err != nil {
+		return fmt.Errorf("-severity: %w", err)
+	}
+	failOn, err := parseOperationList(ctxt.flags.failOn, checkers)
+	if err != nil {
+		return fmt.Errorf("-fail-on: %w", err)
+	}
+	ctxt.failOn = failOn
+
 	ctxt.locs = newLocationMap()
+	ctxt.candidates.Reset()
+	ctxt.candidates.spillThreshold = ctxt.flags.spillThreshold
 	ctxt.checkers = enabledCheckers
+	ctxt.walker = newFileWalker(enabledCheckers, ctxt.timings)
 
 	return nil
 }
 
+// parseOperationList parses a comma-separated list of operations.
+// Operations can be referred by their keys or names.
+// Returns nil for the empty list.
+func parseOperationList(s string, checkers []checker) (map[*operation]bool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	set := make(map[*operation]bool)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		var found *operation
+		for _, c := range checkers {
+			op := c.Operation()
+			if op.key() == name || op.name == name {
+				found = op
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown operation %q", name)
+		}
+		set[found] = true
+	}
+	return set, nil
+}
+
 func (ctxt *context) collectAllCandidates() error {
-	for _, path := range ctxt.paths {
-		ctxt.infoPrintf("check %q", path)
-		if err := ctxt.collectPathCandidates(path); err != nil {
-			return fmt.Errorf("%s: %w", path, err)
+	if ctxt.flags.watch {
+		return ctxt.collectWatchedCandidates()
+	}
+
+	configs := ctxt.buildConfigs
+	if len(configs) == 0 {
+		configs = []buildConfig{{}}
+	}
+	ctxt.seenFiles = make(map[string]struct{})
+
+	for _, p := range ctxt.paths {
+		for _, config := range configs {
+			name := p.path
+			if len(configs) > 1 {
+				name += " " + config.String()
+			}
+			if p.module != "" {
+				ctxt.infoPrintf("check %q (module %s)", name, p.module)
+			} else {
+				ctxt.infoPrintf("check %q", name)
+			}
+			start := time.Now()
+			if err := ctxt.collectCachedPathCandidates(p, config); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			ctxt.timings.addPackage(name, time.Since(start))
 		}
 	}
-	return nil
+	if ctxt.cacheEnabled() {
+		ctxt.infoPrintf("cache: %d hits, %d misses", ctxt.cacheStats.hits, ctxt.cacheStats.misses)
+	}
+	return ctxt.checkPools()
 }
 
 func (ctxt *context) collectPackageCandidates(pkg *packages.Package) {
 	ctxt.info = pkg.TypesInfo
 	for _, f := range pkg.Syntax {
-		isGenerated := len(f.Comments) != 0 &&
-			generatedFileCommentRE.MatchString(f.Comments[0].Text())
-		if isGenerated {
+		filename := ctxt.fset.Position(f.Pos()).Filename
+		if ctxt.onlyFile != "" && filename != ctxt.onlyFile {
+			continue
+		}
+		if !ctxt.markFileSeen(filename) {
+			ctxt.seenSkips++
+			ctxt.infoPrintf("skip %s: already analyzed", filename)
+			continue
+		}
+		if reason := ctxt.skipReason(filename); reason != "" {
+			ctxt.infoPrintf("skip %s: %s", filename, reason)
+			continue
+		}
+		if ctxt.staged != nil && ctxt.staged.untracked[filename] {
+			ctxt.infoPrintf("skip %s: not in the git index", filename)
 			continue
 		}
+		ctxt.pool = ctxt.filePool(filename)
+		ctxt.quiet = ctxt.stdinFile != "" && filename != ctxt.stdinFile
+		if isGeneratedFile(f, ctxt.generatedRE) {
+			switch ctxt.flags.includeGenerated {
+			case generatedCount:
+				ctxt.quiet = true
+			case generatedReport:
+				// Treat it like any other file.
+			default:
+				ctxt.infoPrintf("skip generated file %s", filename)
+				continue
+			}
+		}
 		ctxt.collectFileCandidates(f)
 	}
 }
 
-func (ctxt *context) collectPathCandidates(path string) error {
+// markFileSeen records the file as analyzed.
+// Returns false if it was already analyzed during this run.
+func (ctxt *context) markFileSeen(filename string) bool {
+	key, err := filepath.Abs(filename)
+	if err == nil {
+		if resolved, err := filepath.EvalSymlinks(key); err == nil {
+			key = resolved
+		}
+	} else {
+		key = filename
+	}
+	if _, ok := ctxt.seenFiles[key]; ok {
+		return false
+	}
+	ctxt.seenFiles[key] = struct{}{}
+	if ctxt.onSeen != nil {
+		ctxt.onSeen(key)
+	}
+	return true
+}
+
+func (ctxt *context) collectPathCandidates(p targetPath, config buildConfig) error {
 	ctxt.fset = token.NewFileSet()
 
-	loaderFlags := packages.NeedSyntax | packages.NeedName | packages.NeedFiles
+	loaderFlags := packages.NeedSyntax | packages.NeedName | packages.NeedFiles | packages.NeedModule
 	if !ctxt.flags.noTypes {
 		loaderFlags |= packages.NeedTypes
 		loaderFlags |= packages.NeedTypesInfo
@@ -209,21 +658,40 @@ func (ctxt *context) collectPathCandidates(path string) error {
 	conf := &packages.Config{
 		Mode:  loaderFlags,
 		Fset:  ctxt.fset,
-		Tests: true,
+		Tests: ctxt.flags.tests != testsExclude,
+
+		Dir:        p.dir,
+		BuildFlags: ctxt.withBuildTags(p.buildFlags),
+		Overlay:    ctxt.overlay,
+	}
+	if env := append(config.env(), p.env...); len(env) != 0 {
+		conf.Env = append(os.Environ(), env...)
 	}
 
 	// TODO(Quasilyte): current approach is memory-efficient
 	// and does scale well with huge amounts of targets to check,
 	// but it's not very fast. Might want to optimize it a little bit.
-	pkgs, err := packages.Load(conf, path)
+	pkgs, err := packages.Load(conf, p.path)
 	if err != nil {
 		return err
 	}
 	if len(pkgs) == 0 {
-		ctxt.infoPrintf("got 0 packages for %q path", path)
+		ctxt.infoPrintf("got 0 packages for %q path", p.path)
 		return nil
 	}
-	if n := packages.PrintErrors(pkgs); n > 0 {
+	loaded := pkgs[:0]
+	for _, pkg := range pkgs {
+		if isExcludedByBuildConstraints(pkg) {
+			ctxt.infoPrintf("%s: no files for %s", pkg.PkgPath, config)
+			continue
+		}
+		loaded = append(loaded, pkg)
+	}
+	pkgs = loaded
+	if ctxt.onLoad != nil {
+		ctxt.onLoad(pkgs)
+	}
+	if n := packages.PrintErrors(pkgs); n > 0 && !ctxt.allowErrors {
 		return fmt.Errorf("%d build errors", n)
 	}
 
@@ -243,17 +711,7 @@ func (ctxt *context) collectPathCandidates(path string) error {
 }
 
 func (ctxt *context) collectFileCandidates(f *ast.File) {
-	ctxt.astinfo = astinfo.Info{
-		Parents: make(map[ast.Node]ast.Node),
-	}
-	ctxt.astinfo.Origin = f
-	ctxt.astinfo.Resolve()
-
-	for _, c := range ctxt.checkers {
-		for _, decl := range f.Decls {
-			ast.Inspect(decl, c.Visit)
-		}
-	}
+	ctxt.walker.Walk(f)
 }
 
 func (ctxt *context) assignSuggestions() error {
@@ -265,26 +723,160 @@ func (ctxt *context) assignSuggestions() error {
 				op.suggested = v
 			}
 		}
+
+		op.poolSuggested = op.poolSuggested[:0]
+		for pool := range ctxt.pools {
+			suggested := op.variants[0]
+			for _, v := range op.variants[1:] {
+				if v.poolCount(pool) > suggested.poolCount(pool) {
+					suggested = v
+				}
+			}
+			op.poolSuggested = append(op.poolSuggested, suggested)
+		}
 	}
 	return nil
 }
 
 func (ctxt *context) printWarnings() error {
-	exitCode := 0
-	visitWarnings(ctxt, func(pos token.Position, v *opVariant) {
-		exitCode = 1
-		loc := pos.String()
-		if ctxt.flags.shorterErrLocation {
-			loc = ctxt.shortenLocation(loc)
-		}
-		fmt.Printf("%s: %s: %s\n", loc, v.op.name, v.op.suggested.warning)
+	report := reportFormats[ctxt.flags.format]
+	if report == nil {
+		report = (*context).reportText
+	}
+	n, err := ctxt.writeReport(report)
+	if err != nil {
+		ctxt.candidates.Reset()
+		return err
+	}
+	ctxt.warnings = n
+	ctxt.failures = 0
+	err = visitWarnings(ctxt, func(w warning) {
+		if ctxt.isFailure(w) {
+			ctxt.failures++
+		}
 	})
-	os.Exit(exitCode)
-	return nil
+	ctxt.candidates.Reset()
+	return err
+}
+
+// isFailure reports whether w should fail the check.
+func (ctxt *context) isFailure(w warning) bool {
+	if ctxt.failOn != nil {
+		return ctxt.failOn[w.variant.op]
+	}
+	return w.variant.op.severity == severityError
+}
+
+// exitCode returns the program exit code according
+// to the -exit-code, -max-warnings and -fail-on flags.
+func (ctxt *context) exitCode() int {
+	if ctxt.failures > ctxt.flags.maxWarnings {
+		return ctxt.flags.exitCode
+	}
+	return 0
+}
+
+// writeReport runs the reporter over the -o file or stdout, if the
+// file is not specified. Returns the number of reported warnings.
+func (ctxt *context) writeReport(report func(ctxt *context, w io.Writer) (int, error)) (int, error) {
+	if ctxt.flags.output == "" {
+		return report(ctxt, os.Stdout)
+	}
+	f, err := os.Create(ctxt.flags.output)
+	if err != nil {
+		return 0, err
+	}
+	n, err := report(ctxt, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// warning describes a single reported inconsistency.
+//
+// It's also used by visitCandidates to describe consistent candidates,
+// for them, the variant and suggested fields are identical.
+type warning struct {
+	pos token.Position
+
+	// variant is an op variant that was found at pos.
+	variant *opVariant
+
+	// suggested is an op variant that should be used instead.
+	suggested *opVariant
+
+	// pool is a name of the voting pool that inferred the suggestion.
+	pool string
+
+	// poolID is an ID of the voting pool.
+	poolID int
+
+	// module is a path of the module that owns the pos file.
+	module string
+
+	// quiet is set for candidates that should never be reported.
+	quiet bool
+
+	// owners are the pos file owners, see -codeowners.
+	owners []string
+}
+
+// visitWarnings calls visit for every reported inconsistency.
+func visitWarnings(ctxt *context, visit func(w warning)) error {
+	return visitCandidates(ctxt, func(w warning) {
+		if w.reported() {
+			visit(w)
+		}
+	})
+}
+
+// reported reports whether w is an inconsistency that should be reported.
+func (w *warning) reported() bool {
+	if w.suggested == w.variant {
+		return false // OK, everything is consistent
+	}
+	// Quiet candidates only vote, see -include-generated and -staged.
+	return !w.quiet
 }
 
-func visitWarnings(ctxt *context, visit func(pos token.Position, v *opVariant)) {
-	// Build variant map which is accessed by variantID.
+// visitCandidates calls visit for every collected candidate,
+// including the ones that use the suggested variant.
+func visitCandidates(ctxt *context, visit func(w warning)) error {
+	variants := ctxt.variantsByID()
+	return ctxt.candidates.Visit(func(c candidate) {
+		v := variants[c.variantID]
+		suggested := v.op.suggestedFor(int(c.pool))
+		if c.mismatch {
+			v = v.against(suggested)
+		}
+		pos := ctxt.locs.Get(c.loc)
+		w := warning{
+			pos:       pos,
+			variant:   v,
+			suggested: suggested,
+			pool:      ctxt.pools[c.pool],
+			poolID:    int(c.pool),
+			module:    ctxt.moduleOf(pos.Filename),
+			quiet:     c.quiet,
+		}
+		if ctxt.stdinFile != "" && pos.Filename == ctxt.stdinFile {
+			// Refer to the file the same way the user did.
+			w.pos.Filename = ctxt.flags.stdinFilename
+		}
+		if ctxt.codeowners != nil {
+			w.owners = ctxt.codeowners.Owners(pos.Filename)
+		}
+		if ctxt.staged != nil && !ctxt.staged.contains(pos.Filename, pos.Line) {
+			// Only votes, see -staged.
+			w.quiet = true
+		}
+		visit(w)
+	})
+}
+
+// variantsByID returns all enabled op variants, indexed by their IDs.
+func (ctxt *context) variantsByID() []*opVariant {
 	vcount := 0
 	for _, c := range ctxt.checkers {
 		vcount += len(c.Operation().variants)
@@ -295,15 +887,7 @@ func visitWarnings(ctxt *context, visit func(pos token.Position, v *opVariant))
 			variants[v.id] = v
 		}
 	}
-
-	for _, c := range ctxt.candidates {
-		v := variants[c.variantID]
-		if v.op.suggested == v {
-			continue // OK, everything is consistent
-		}
-		pos := ctxt.locs.Get(c.locationID)
-		visit(pos, v)
-	}
+	return variants
 }
 
 func (ctxt *context) shortenLocation(loc string) string {
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// analyzeTestdata runs the analysis over the testdata paths,
+// so the ctxt candidates and suggestions can be inspected or reported.
+func analyzeTestdata(t *testing.T, ctxt *context, paths ...string) {
+	t.Helper()
+	ctxt.paths = nil
+	for _, path := range paths {
+		ctxt.paths = append(ctxt.paths, targetPath{path: path})
+	}
+	if err := ctxt.initCheckers(); err != nil {
+		t.Fatalf("init checkers: %v", err)
+	}
+	if err := ctxt.collectAllCandidates(); err != nil {
+		t.Fatalf("collect candidates: %v", err)
+	}
+	if err := ctxt.assignSuggestions(); err != nil {
+		t.Fatalf("assign suggestions: %v", err)
+	}
+}
+
+func TestOverlappingTargets(t *testing.T) {
+	var ctxt context
+	analyzeTestdata(t, &ctxt,
+		"./testdata/tests_separate",
+		"./testdata/tests_separate/alloc.go",
+		"./testdata/tests_separate")
+
+	for _, c := range ctxt.checkers {
+		op := c.Operation()
+		if op.name != "zero value ptr alloc" {
+			continue
+		}
+		counts := []int{op.variants[0].count, op.variants[1].count}
+		if counts[0] != 4 || counts[1] != 3 {
+			t.Errorf("%s: have %v counts, want [4 3]", op.name, counts)
+		}
+	}
+
+	warnings := 0
+	err := visitWarnings(&ctxt, func(w warning) {
+		warnings++
+	})
+	if err != nil {
+		t.Fatalf("visit warnings: %v", err)
+	}
+	if warnings != 3 {
+		t.Errorf("have %d warnings, want 3", warnings)
+	}
+}
+
+func TestStdinFile(t *testing.T) {
+	var ctxt context
+	src := "package alloc\n\ntype T struct{}\n\nfunc fromStdin() {\n\t_ = &T{}\n\t_ = &T{}\n\t_ = new(T)\n}\n"
+	filename := "./testdata/tests_separate/alloc.go"
+	if err := ctxt.setStdinFile(filename, strings.NewReader(src)); err != nil {
+		t.Fatalf("set stdin file: %v", err)
+	}
+	ctxt.flags.stdinFilename = filename
+	analyzeTestdata(t, &ctxt, "./testdata/tests_separate")
+
+	// Stdin contents replace the new(T)-heavy alloc.go, so &T{} wins 4 to 2.
+	// new(T) from the test file is not reported, only the stdin file is.
+	var warnings []string
+	err := visitWarnings(&ctxt, func(w warning) {
+		warnings = append(warnings, w.pos.String())
+	})
+	if err != nil {
+		t.Fatalf("visit warnings: %v", err)
+	}
+	want := []string{"./testdata/tests_separate/alloc.go:8:6"}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("have %v warnings, want %v", warnings, want)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		exitCode    int
+		maxWarnings int
+		failOn      string
+		severity    string
+		want        int
+	}{
+		{exitCode: 1, want: 1},
+		{exitCode: 3, want: 3},
+		{exitCode: 0, want: 0},
+		{exitCode: 1, maxWarnings: 1, want: 0},
+		{exitCode: 1, failOn: "hex-lit,empty map", want: 0},
+		{exitCode: 2, failOn: "hex-lit,label-case", want: 2},
+		{exitCode: 1, failOn: "label case,zero-value-ptr-alloc", maxWarnings: 1, want: 1},
+		{exitCode: 1, failOn: "label-case", maxWarnings: 1, want: 0},
+
+		// Only errors fail the check by default.
+		{exitCode: 1, severity: "zero-value-ptr-alloc=warning", want: 0},
+		{exitCode: 1, severity: "zero value ptr alloc=info", want: 0},
+		{exitCode: 1, severity: "zero-value-ptr-alloc=warning", failOn: "zero-value-ptr-alloc", want: 1},
+		{exitCode: 1, severity: "label-case=error", maxWarnings: 1, want: 1},
+		{exitCode: 1, severity: "hex-lit=warning", want: 1},
+	}
+
+	for _, test := range tests {
+		var ctxt context
+		ctxt.flags.exitCode = test.exitCode
+		ctxt.flags.maxWarnings = test.maxWarnings
+		ctxt.flags.failOn = test.failOn
+		ctxt.flags.severity = test.severity
+		ctxt.flags.output = filepath.Join(t.TempDir(), "report.txt")
+		ctxt.paths = []targetPath{{path: "./testdata/severities"}}
+
+		// Run the entire pipeline, including the reporting.
+		steps := []func() error{
+			ctxt.initCheckers,
+			ctxt.collectAllCandidates,
+			ctxt.assignSuggestions,
+			ctxt.printWarnings,
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				t.Fatalf("%+v: %v", test, err)
+			}
+		}
+		if ctxt.warnings != 2 {
+			t.Errorf("%+v: have %d warnings, want 2", test, ctxt.warnings)
+		}
+		if have := ctxt.exitCode(); have != test.want {
+			t.Errorf("%+v: have %d exit code, want %d", test, have, test.want)
+		}
+	}
+
+	var ctxt context
+	ctxt.flags.failOn = "no-such-op"
+	if err := ctxt.initCheckers(); err == nil {
+		t.Errorf("unknown -fail-on operation: expected an error")
+	}
+	for _, severity := range []string{"hex-lit=fatal", "hex-lit", "no-such-op=info"} {
+		var ctxt context
+		ctxt.flags.severity = severity
+		if err := ctxt.initCheckers(); err == nil {
+			t.Errorf("-severity=%s: expected an error", severity)
+		}
+	}
+
+	// Operations that require types can be referred in the -syntax-only mode.
+	ctxt = context{}
+	ctxt.flags.noTypes = true
+	ctxt.flags.failOn = "empty-map,zero-value-ptr-alloc"
+	ctxt.flags.severity = "zero-value-ptr-alloc=info,empty-map=warning"
+	if err := ctxt.initCheckers(); err != nil {
+		t.Errorf("-syntax-only: %v", err)
+	}
+	for _, c := range ctxt.checkers {
+		if c.Operation().needTypes {
+			t.Errorf("-syntax-only: %s is enabled", c.Operation().name)
+		}
+	}
+}
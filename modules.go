@@ -0,0 +1,365 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+// Voting scopes (see -scope flag).
+const (
+	scopeProject = "project"
+	scopeModule  = "module"
+)
+
+// targetPath is a single package (or a Go file) that is loaded
+// and analyzed separately from the others.
+type targetPath struct {
+	// path is an import path or a Go file name.
+	path string
+
+	// dir is a directory to run the build system from.
+	// Empty string means the working directory.
+	dir string
+
+	// module is an owning module path.
+	// Empty for packages outside of any module (like std or GOPATH packages).
+	module string
+
+	// env is a list of additional environment variables for the build system.
+	env []string
+
+	// buildFlags is a list of additional build system flags.
+	buildFlags []string
+}
+
+// goModule is a Go module that contains some of the targets.
+type goModule struct {
+	dir  string
+	path string
+
+	// inWorkspace is set for modules that are listed in the active go.work file.
+	inWorkspace bool
+}
+
+// expandTargets converts the command-line targets into a list of packages to load.
+//
+// Directory patterns are resolved with module awareness:
+// a recursive pattern like ./... also covers all nested modules,
+// every module is loaded from its own root directory, so each of them
+// can use its own dependencies list.
+func (ctxt *context) expandTargets(targets []string) ([]targetPath, error) {
+	ctxt.workspaceModules = ctxt.findWorkspaceModules()
+
+	var result []targetPath
+	seen := make(map[string]bool)
+	add := func(p targetPath) {
+		key := p.dir + "\x00" + p.path
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, p)
+		}
+	}
+
+	for _, target := range targets {
+		switch {
+		case strings.HasSuffix(target, ".go"):
+			abs, err := filepath.Abs(target)
+			if err != nil {
+				return nil, err
+			}
+			p := targetPath{path: target}
+			if m := ctxt.findModule(filepath.Dir(abs)); m != nil {
+				p = ctxt.moduleTarget(m, abs)
+			}
+			add(p)
+
+		case isDirPattern(target):
+			pkgs, err := ctxt.expandDirPattern(target)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range pkgs {
+				add(p)
+			}
+
+		default:
+			pkgs, err := ctxt.listPackages(targetPath{path: target})
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range pkgs {
+				add(p)
+			}
+		}
+	}
+
+	// Same package can be reachable from several modules
+	// in the workspace mode, keep only the first one.
+	paths := result[:0]
+	seenPkg := make(map[string]bool)
+	for _, p := range result {
+		if seenPkg[p.path] {
+			continue
+		}
+		seenPkg[p.path] = true
+		paths = append(paths, p)
+	}
+
+	return paths, nil
+}
+
+// expandDirPattern expands a filesystem-based pattern like ./foo or ./foo/...
+func (ctxt *context) expandDirPattern(pattern string) ([]targetPath, error) {
+	base := strings.TrimSuffix(pattern, "/...")
+	recursive := base != pattern
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return nil, err
+	}
+
+	var roots []*goModule
+	if m := ctxt.findModule(absBase); m != nil {
+		roots = append(roots, m)
+	}
+	if recursive {
+		nested, err := ctxt.findNestedModules(absBase)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, nested...)
+	}
+
+	if len(roots) == 0 {
+		// Not inside any module; let the build system decide.
+		return ctxt.listPackages(targetPath{path: pattern})
+	}
+
+	var result []targetPath
+	for _, m := range roots {
+		rel, err := filepath.Rel(m.dir, absBase)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = "." // Nested module, check it entirely
+		}
+		path := "."
+		if rel != "." {
+			path = "./" + filepath.ToSlash(rel)
+		}
+		p := ctxt.moduleTarget(m, path)
+		if recursive {
+			p.path += "/..."
+		}
+		pkgs, err := ctxt.listPackages(p)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, pkgs...)
+	}
+	return result, nil
+}
+
+// moduleTarget returns a target for path that should be loaded from the m module root.
+func (ctxt *context) moduleTarget(m *goModule, path string) targetPath {
+	p := targetPath{path: path, dir: m.dir, module: m.path}
+	if ctxt.workspaceModules != nil && !m.inWorkspace {
+		// Module is not a part of the workspace, so the
+		// build system can't load it in the workspace mode.
+		p.env = append(p.env, "GOWORK=off")
+	}
+	if ctxt.flags.modfile != "" && m.dir == ctxt.findModuleRoot(ctxt.workDir) {
+		p.buildFlags = append(p.buildFlags, "-modfile="+ctxt.flags.modfile)
+	}
+	return p
+}
+
+// listPackages expands p pattern into a list of concrete packages.
+func (ctxt *context) listPackages(p targetPath) ([]targetPath, error) {
+	if p.dir == "" && ctxt.flags.modfile != "" {
+		p.buildFlags = append(p.buildFlags, "-modfile="+ctxt.flags.modfile)
+	}
+
+	// Some packages can be matched only under the specific build config,
+	// so every config from the build matrix is queried.
+	configs := ctxt.buildConfigs
+	if len(configs) == 0 {
+		configs = []buildConfig{{}}
+	}
+
+	var result []targetPath
+	seen := make(map[string]bool)
+	for _, config := range configs {
+		conf := &packages.Config{
+			Mode:       packages.NeedName | packages.NeedModule,
+			Dir:        p.dir,
+			BuildFlags: ctxt.withBuildTags(p.buildFlags),
+		}
+		if env := append(config.env(), p.env...); len(env) != 0 {
+			conf.Env = append(os.Environ(), env...)
+		}
+		pkgs, err := packages.Load(conf, p.path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.path, err)
+		}
+
+		for _, pkg := range pkgs {
+			if pkg.PkgPath == "" || pkg.PkgPath == "command-line-arguments" || seen[pkg.PkgPath] {
+				continue
+			}
+			seen[pkg.PkgPath] = true
+			pkgTarget := targetPath{
+				path:       pkg.PkgPath,
+				dir:        p.dir,
+				env:        p.env,
+				buildFlags: p.buildFlags,
+			}
+			if pkg.Module != nil {
+				pkgTarget.module = pkg.Module.Path
+				ctxt.addModule(pkg.Module.Dir, pkg.Module.Path)
+			}
+			result = append(result, pkgTarget)
+		}
+	}
+	if len(result) == 0 {
+		ctxt.infoPrintf("%q matched no packages", p.path)
+	}
+	return result, nil
+}
+
+// findModule returns a module that contains dir.
+// Returns nil if dir is outside of any module.
+func (ctxt *context) findModule(dir string) *goModule {
+	root := ctxt.findModuleRoot(dir)
+	if root == "" {
+		return nil
+	}
+	return ctxt.loadModule(root)
+}
+
+// findNestedModules returns all modules under the dir, excluding dir itself.
+func (ctxt *context) findNestedModules(dir string) ([]*goModule, error) {
+	var modules []*goModule
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == dir {
+			return nil
+		}
+		// Same rules as the go command uses for "..." patterns.
+		name := d.Name()
+		if name == "vendor" || name == "testdata" ||
+			strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+			return filepath.SkipDir
+		}
+		if fileExists(filepath.Join(path, "go.mod")) {
+			if m := ctxt.loadModule(path); m != nil {
+				modules = append(modules, m)
+			}
+		}
+		return nil
+	})
+	return modules, err
+}
+
+// findModuleRoot returns the closest dir parent that contains go.mod file.
+func (ctxt *context) findModuleRoot(dir string) string {
+	for {
+		if fileExists(filepath.Join(dir, "go.mod")) {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadModule returns a module descriptor for the module root dir.
+func (ctxt *context) loadModule(dir string) *goModule {
+	data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		ctxt.infoPrintf("read go.mod: %v", err)
+		return nil
+	}
+	path := modfile.ModulePath(data)
+	if path == "" {
+		ctxt.infoPrintf("%s/go.mod: can't find a module path", dir)
+		return nil
+	}
+	ctxt.addModule(dir, path)
+	m := &goModule{dir: dir, path: path}
+	if ctxt.workspaceModules != nil {
+		m.inWorkspace = ctxt.workspaceModules[dir]
+	}
+	return m
+}
+
+// addModule records that dir is a root of the module with the specified path.
+func (ctxt *context) addModule(dir, path string) {
+	if dir == "" {
+		return
+	}
+	if ctxt.moduleDirs == nil {
+		ctxt.moduleDirs = make(map[string]string)
+	}
+	if _, ok := ctxt.moduleDirs[dir]; !ok {
+		ctxt.infoPrintf("found module %s in %s", path, dir)
+		ctxt.moduleDirs[dir] = path
+	}
+}
+
+// moduleOf returns a path of the module that owns the file.
+// Returns an empty string for files outside of the known modules.
+func (ctxt *context) moduleOf(filename string) string {
+	for dir := filepath.Dir(filename); ; {
+		if path, ok := ctxt.moduleDirs[dir]; ok {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// findWorkspaceModules returns a set of workspace module dirs.
+// Returns nil if the workspace mode is not active.
+func (ctxt *context) findWorkspaceModules() map[string]bool {
+	out, err := exec.Command("go", "env", "GOWORK").Output()
+	if err != nil {
+		ctxt.infoPrintf("go env GOWORK: %v", err)
+		return nil
+	}
+	gowork := strings.TrimSpace(string(out))
+	if gowork == "" || gowork == "off" {
+		return nil
+	}
+	ctxt.infoPrintf("workspace mode is active, using %s", gowork)
+
+	out, err = exec.Command("go", "list", "-m", "-f", "{{.Dir}}").Output()
+	if err != nil {
+		ctxt.infoPrintf("go list workspace modules: %v", err)
+		return nil
+	}
+	dirs := make(map[string]bool)
+	for _, dir := range strings.Fields(string(out)) {
+		dirs[dir] = true
+	}
+	return dirs
+}
+
+// isDirPattern reports whether pattern refers to a filesystem location
+// rather than to an import path.
+func isDirPattern(pattern string) bool {
+	return pattern == "." || pattern == ".." ||
+		strings.HasPrefix(pattern, "./") || strings.HasPrefix(pattern, "../") ||
+		filepath.IsAbs(pattern)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpandTargetsNestedModules(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":               "module example.com/root\n\ngo 1.20\n",
+		"a/a.go":               "package a\n",
+		"nested/go.mod":        "module example.com/nested\n\ngo 1.20\n",
+		"nested/b/b.go":        "package b\n",
+		"nested/testdata/x.go": "package x\n",
+	}
+	for name, contents := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ctxt context
+	paths, err := ctxt.expandTargets([]string{root + "/..."})
+	if err != nil {
+		t.Fatalf("expand targets: %v", err)
+	}
+
+	want := []targetPath{
+		{path: "example.com/root/a", dir: root, module: "example.com/root"},
+		{path: "example.com/nested/b", dir: filepath.Join(root, "nested"), module: "example.com/nested"},
+	}
+	if len(paths) != len(want) {
+		t.Fatalf("expand targets: have %d paths, want %d", len(paths), len(want))
+	}
+	for i := range want {
+		have := paths[i]
+		if have.path != want[i].path || have.dir != want[i].dir || have.module != want[i].module {
+			t.Errorf("paths[%d]: have %+v, want %+v", i, have, want[i])
+		}
+	}
+
+	ownerTests := map[string]string{
+		"a/a.go":        "example.com/root",
+		"nested/b/b.go": "example.com/nested",
+		"nested/c.go":   "example.com/nested",
+	}
+	for name, module := range ownerTests {
+		have := ctxt.moduleOf(filepath.Join(root, filepath.FromSlash(name)))
+		if have != module {
+			t.Errorf("moduleOf(%s): have %q, want %q", name, have, module)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runPlan implements the "plan" subcommand.
+//
+// It lists all candidates of the operation that don't use the
+// specified variant, regardless of the vote results.
+// The candidates are grouped by package, or by owner
+// with -group-by=owner.
+// With -patches flag, the fixable candidates are rewritten
+// into per-package patch files, so the migration can be split
+// into several independent changes.
+//
+// Usage: go-consistent plan -op=KEY -to=VARIANT [-patches=DIR] [flags] [targets]
+//
+// If targets are not specified, ./... is used.
+func runPlan(args []string) error {
+	var ctxt context
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	opName := fs.String("op", "",
+		`operation to migrate, like "empty-slice"`)
+	variantName := fs.String("to", "",
+		`variant to migrate to; either its number (starting from 1) or its warning text without "use", like "[]T{}"`)
+	patchesDir := fs.String("patches", "",
+		`write a patch file with the fixes for every package into the specified directory`)
+	if err := ctxt.parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *opName == "" || *variantName == "" {
+		return errors.New("usage: go-consistent plan -op=KEY -to=VARIANT [-patches=DIR] [flags] [targets]")
+	}
+	if len(ctxt.flags.targets) == 0 {
+		ctxt.flags.targets = []string{"./..."}
+	}
+
+	if err := ctxt.resolveTargets(); err != nil {
+		return err
+	}
+	if err := ctxt.initCheckers(); err != nil {
+		return err
+	}
+	op, err := findOperation(*opName, ctxt.checkers)
+	if err != nil {
+		return fmt.Errorf("-op: %w", err)
+	}
+	to, err := findVariant(op, *variantName)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+
+	p, err := collectPlan(&ctxt, op, to)
+	if err != nil {
+		return err
+	}
+	p.Print(&ctxt, os.Stdout)
+	if *patchesDir != "" {
+		return p.WritePatches(&ctxt, *patchesDir)
+	}
+	return nil
+}
+
+// findOperation returns the only operation that is specified by s,
+// see parseOperationList.
+func findOperation(s string, checkers []checker) (*operation, error) {
+	ops, err := parseOperationList(s, checkers)
+	if err != nil {
+		return nil, err
+	}
+	if len(ops) != 1 {
+		return nil, fmt.Errorf("exactly one operation is expected, have %d", len(ops))
+	}
+	var op *operation
+	for o := range ops {
+		op = o
+	}
+	return op, nil
+}
+
+// findVariant returns the op variant that is specified by its
+// 1-based index or its warning text, with or without the "use" prefix.
+func findVariant(op *operation, s string) (*opVariant, error) {
+	if i, err := strconv.Atoi(s); err == nil {
+		if i < 1 || i > len(op.variants) {
+			return nil, fmt.Errorf("%s has no variant %d", op.name, i)
+		}
+		return op.variants[i-1], nil
+	}
+	for _, v := range op.variants {
+		if v.warning == s || strings.TrimPrefix(v.warning, "use ") == s {
+			return v, nil
+		}
+	}
+	var names []string
+	for i, v := range op.variants {
+		names = append(names, fmt.Sprintf("%d (%s)", i+1, v.warning))
+	}
+	return nil, fmt.Errorf("%s has no variant %q, available variants: %s",
+		op.name, s, strings.Join(names, ", "))
+}
+
+// migrationPlan is a list of the candidates that should be rewritten.
+type migrationPlan struct {
+	op *operation
+	to *opVariant
+
+	// packages are the candidates grouped by their package directory.
+	packages map[string][]planItem
+
+	fixable int
+}
+
+type planItem struct {
+	pos  token.Position
+	text string
+
+	// edit is a fix for the candidate; nil if it can't be fixed automatically.
+	edit *textEdit
+
+	// owners are the pos file owners, see -codeowners.
+	owners []string
+}
+
+// collectPlan finds all op candidates that don't use the to variant.
+// The checkers should be already initialized.
+func collectPlan(ctxt *context, op *operation, to *opVariant) (*migrationPlan, error) {
+	var c checker
+	for _, c2 := range ctxt.checkers {
+		if c2.Operation() == op {
+			c = c2
+		}
+	}
+
+	p := &migrationPlan{
+		op:       op,
+		to:       to,
+		packages: make(map[string][]planItem),
+	}
+	ctxt.markHook = func(n ast.Node, v *opVariant) {
+		if ctxt.mismatch {
+			v = v.against(to)
+		}
+		if v.op != op || v == to || ctxt.quiet {
+			return
+		}
+		item := planItem{
+			pos:  ctxt.fset.Position(n.Pos()),
+			text: ctxt.nodeText(n),
+		}
+		if edit, ok := ctxt.suggestFix(c, n, to); ok {
+			item.edit = &edit
+			p.fixable++
+		}
+		if ctxt.codeowners != nil {
+			item.owners = ctxt.codeowners.Owners(item.pos.Filename)
+		}
+		dir := filepath.Dir(item.pos.Filename)
+		p.packages[dir] = append(p.packages[dir], item)
+	}
+	defer func() {
+		ctxt.markHook = nil
+		ctxt.candidates.Reset()
+	}()
+
+	if err := ctxt.collectAllCandidates(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// dirs returns the plan package directories in a sorted order.
+func (p *migrationPlan) dirs() []string {
+	dirs := make([]string, 0, len(p.packages))
+	for dir := range p.packages {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+func (p *migrationPlan) total() int {
+	total := 0
+	for _, items := range p.packages {
+		total += len(items)
+	}
+	return total
+}
+
+// byOwner returns the plan items grouped by their owners.
+// Items with several owners are included into every owner group.
+// Owners are listed in the order of their first item,
+// with the unowned items group at the end.
+func (p *migrationPlan) byOwner() (owners []string, groups map[string][]planItem) {
+	groups = make(map[string][]planItem)
+	add := func(owner string, item planItem) {
+		if _, ok := groups[owner]; !ok && owner != unownedGroup {
+			owners = append(owners, owner)
+		}
+		groups[owner] = append(groups[owner], item)
+	}
+	for _, dir := range p.dirs() {
+		for _, item := range p.packages[dir] {
+			if len(item.owners) == 0 {
+				add(unownedGroup, item)
+				continue
+			}
+			for _, owner := range item.owners {
+				add(owner, item)
+			}
+		}
+	}
+	if _, ok := groups[unownedGroup]; ok {
+		owners = append(owners, unownedGroup)
+	}
+	return owners, groups
+}
+
+// Print writes the plan items grouped by package,
+// or by owner with -group-by=owner.
+func (p *migrationPlan) Print(ctxt *context, w io.Writer) {
+	total := p.total()
+	fmt.Fprintf(w, "%s: %d %s to migrate to %q (%d fixable) in %d %s\n",
+		p.op.name, total, plural(total, "candidate"), p.to.warning,
+		p.fixable, len(p.packages), plural(len(p.packages), "package"))
+
+	// Locations are formatted the same way as in the text report.
+	location := func(loc string) string {
+		if ctxt.flags.shorterErrLocation {
+			return ctxt.shortenLocation(loc)
+		}
+		return loc
+	}
+	printItems := func(group string, items []planItem) {
+		fmt.Fprintf(w, "%s: %d\n", group, len(items))
+		for _, item := range items {
+			loc := location(item.pos.String())
+			if item.edit == nil {
+				fmt.Fprintf(w, "  %s: %s (manual)%s\n", loc, item.text, ownersSuffix(item.owners))
+			} else {
+				fmt.Fprintf(w, "  %s: %s%s\n", loc, item.text, ownersSuffix(item.owners))
+			}
+		}
+	}
+	if ctxt.flags.groupBy == groupByOwner {
+		owners, groups := p.byOwner()
+		for _, owner := range owners {
+			printItems(owner, groups[owner])
+		}
+		return
+	}
+	for _, dir := range p.dirs() {
+		printItems(location(dir), p.packages[dir])
+	}
+}
+
+// WritePatches writes a patch file for every package that has fixable candidates.
+// The file names inside the patches are relative to the working directory.
+func (p *migrationPlan) WritePatches(ctxt *context, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, pkgDir := range p.dirs() {
+		edits := make(map[string][]textEdit)
+		var files []string
+		for _, item := range p.packages[pkgDir] {
+			if item.edit == nil {
+				continue
+			}
+			filename := item.pos.Filename
+			if _, ok := edits[filename]; !ok {
+				files = append(files, filename)
+			}
+			edits[filename] = append(edits[filename], *item.edit)
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		var patch strings.Builder
+		for _, filename := range files {
+			src, ok := ctxt.overlay[filename]
+			if !ok {
+				var err error
+				src, err = os.ReadFile(filename)
+				if err != nil {
+					return err
+				}
+			}
+			name := ctxt.relativePath(filename)
+			if err := writeUnifiedDiff(&patch, name, string(src), edits[filename]); err != nil {
+				return fmt.Errorf("%s: %w", filename, err)
+			}
+		}
+
+		patchName := filepath.Join(dir, patchFileName(ctxt.relativePath(pkgDir)))
+		if err := os.WriteFile(patchName, []byte(patch.String()), 0o644); err != nil {
+			return err
+		}
+		ctxt.infoPrintf("wrote %s", patchName)
+	}
+	return nil
+}
+
+// patchFileName returns a patch file name for the package directory
+// that is relative to the working directory.
+// Both "a/b-c" and "a-b/c" are flattened into "a-b-c",
+// so a short hash of the path is added to keep the names distinct.
+func patchFileName(pkgDir string) string {
+	if pkgDir == "." {
+		return "root.patch"
+	}
+	pkgDir = filepath.ToSlash(pkgDir)
+	sum := sha256.Sum256([]byte(pkgDir))
+	return fmt.Sprintf("%s-%x.patch", strings.ReplaceAll(pkgDir, "/", "-"), sum[:4])
+}
+
+// diffContext is a number of unchanged lines around every patch hunk.
+const diffContext = 3
+
+// writeUnifiedDiff writes a unified diff of applying the edits to the src.
+func writeUnifiedDiff(w io.Writer, name, src string, edits []textEdit) error {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].pos.Offset < edits[j].pos.Offset
+	})
+
+	oldLines := strings.SplitAfter(src, "\n")
+	if oldLines[len(oldLines)-1] == "" {
+		oldLines = oldLines[:len(oldLines)-1]
+	}
+	lineStarts := make([]int, len(oldLines)+1)
+	for i, line := range oldLines {
+		lineStarts[i+1] = lineStarts[i] + len(line)
+	}
+
+	// Every change replaces the [from, to) lines range with the new lines.
+	// The edits that share the lines are merged into a single change.
+	type change struct {
+		from, to int
+		newLines []string
+	}
+	var changes []change
+	for i := 0; i < len(edits); {
+		from, to := edits[i].pos.Line-1, edits[i].end.Line
+		j := i + 1
+		for j < len(edits) && edits[j].pos.Line-1 < to {
+			if edits[j].pos.Offset < edits[j-1].end.Offset {
+				return fmt.Errorf("overlapping edits at line %d", edits[j].pos.Line)
+			}
+			if edits[j].end.Line > to {
+				to = edits[j].end.Line
+			}
+			j++
+		}
+		if to > len(oldLines) {
+			return fmt.Errorf("edit at line %d is out of the file bounds", edits[i].pos.Line)
+		}
+		var b strings.Builder
+		offset := lineStarts[from]
+		for _, e := range edits[i:j] {
+			b.WriteString(src[offset:e.pos.Offset])
+			b.WriteString(e.newText)
+			offset = e.end.Offset
+		}
+		b.WriteString(src[offset:lineStarts[to]])
+		newLines := strings.SplitAfter(b.String(), "\n")
+		if newLines[len(newLines)-1] == "" {
+			newLines = newLines[:len(newLines)-1]
+		}
+		changes = append(changes, change{from: from, to: to, newLines: newLines})
+		i = j
+	}
+
+	fmt.Fprintf(w, "--- a/%s\n+++ b/%s\n", name, name)
+	delta := 0 // New lines count minus the old lines count before the hunk
+	for i := 0; i < len(changes); {
+		// Merge the changes whose context lines overlap into a single hunk.
+		j := i + 1
+		for j < len(changes) && changes[j].from-changes[j-1].to <= 2*diffContext {
+			j++
+		}
+		from := changes[i].from - diffContext
+		if from < 0 {
+			from = 0
+		}
+		to := changes[j-1].to + diffContext
+		if to > len(oldLines) {
+			to = len(oldLines)
+		}
+
+		var body strings.Builder
+		oldCount, newCount := 0, 0
+		line := from
+		for _, c := range changes[i:j] {
+			for ; line < c.from; line++ {
+				writeDiffLine(&body, ' ', oldLines[line])
+				oldCount++
+				newCount++
+			}
+			for ; line < c.to; line++ {
+				writeDiffLine(&body, '-', oldLines[line])
+				oldCount++
+			}
+			for _, l := range c.newLines {
+				writeDiffLine(&body, '+', l)
+				newCount++
+			}
+		}
+		for ; line < to; line++ {
+			writeDiffLine(&body, ' ', oldLines[line])
+			oldCount++
+			newCount++
+		}
+
+		fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", from+1, oldCount, from+1+delta, newCount)
+		io.WriteString(w, body.String())
+		delta += newCount - oldCount
+		i = j
+	}
+	return nil
+}
+
+func writeDiffLine(b *strings.Builder, op byte, line string) {
+	b.WriteByte(op)
+	b.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		b.WriteString("\n\\ No newline at end of file\n")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	src := `package foo
+
+var (
+	a = make(map[int]int)
+	b = make(map[int]int)
+)
+
+var c = 1
+var d = 2
+var e = 3
+var f = 4
+var g = 5
+var h = 6
+var i = 7
+var j = make([]int, 0)
+`
+	edit := func(text, newText string) textEdit {
+		offset := strings.Index(src, text)
+		if offset == -1 {
+			t.Fatalf("%q not found", text)
+		}
+		posOf := func(offset int) token.Position {
+			line := strings.Count(src[:offset], "\n") + 1
+			return token.Position{Offset: offset, Line: line}
+		}
+		return textEdit{pos: posOf(offset), end: posOf(offset + len(text)), newText: newText}
+	}
+
+	var out strings.Builder
+	edits := []textEdit{
+		edit("make([]int, 0)", "[]int{}"),
+		edit("a = make(map[int]int)\n\t", "a = map[int]int{}\n\t"),
+		edit("b = make(map[int]int)", "b = map[int]int{}"),
+	}
+	if err := writeUnifiedDiff(&out, "foo.go", src, edits); err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	want := `--- a/foo.go
++++ b/foo.go
+@@ -1,8 +1,8 @@
+ package foo
+ 
+ var (
+-	a = make(map[int]int)
+-	b = make(map[int]int)
++	a = map[int]int{}
++	b = map[int]int{}
+ )
+ 
+ var c = 1
+@@ -12,4 +12,4 @@
+ var g = 5
+ var h = 6
+ var i = 7
+-var j = make([]int, 0)
++var j = []int{}
+`
+	if out.String() != want {
+		t.Errorf("diff mismatch:\nhave:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestFindVariant(t *testing.T) {
+	var ctxt context
+	c := newEmptySliceChecker(&ctxt)
+	op := c.Operation()
+
+	for _, s := range []string{"2", "[]T{}", "use []T{}"} {
+		v, err := findVariant(op, s)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", s, err)
+			continue
+		}
+		if v != op.variants[1] {
+			t.Errorf("%q: have %q variant, want %q", s, v.warning, op.variants[1].warning)
+		}
+	}
+	for _, s := range []string{"0", "3", "new([]T)"} {
+		if _, err := findVariant(op, s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestFindOperation(t *testing.T) {
+	var ctxt context
+	checkers := []checker{newEmptySliceChecker(&ctxt), newEmptyMapChecker(&ctxt)}
+
+	for _, s := range []string{"empty-slice", "empty slice", "empty-slice, empty slice"} {
+		op, err := findOperation(s, checkers)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", s, err)
+			continue
+		}
+		if op != checkers[0].Operation() {
+			t.Errorf("%q: have %q operation, want %q", s, op.name, checkers[0].Operation().name)
+		}
+	}
+	for _, s := range []string{"", ",", "empty-slice,empty-map", "hex-lit"} {
+		if _, err := findOperation(s, checkers); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestPatchFileName(t *testing.T) {
+	if have := patchFileName("."); have != "root.patch" {
+		t.Errorf("have %q root patch, want root.patch", have)
+	}
+
+	names := make(map[string]string)
+	for _, dir := range []string{"a/b-c", "a-b/c", "a-b-c", "a/b/c", "root"} {
+		name := patchFileName(dir)
+		if other, ok := names[name]; ok {
+			t.Errorf("%q and %q share %q patch", other, dir, name)
+		}
+		names[name] = dir
+		if !strings.HasPrefix(name, strings.ReplaceAll(dir, "/", "-")+"-") || !strings.HasSuffix(name, ".patch") {
+			t.Errorf("%q: unexpected patch name %q", dir, name)
+		}
+	}
+}
+
+func TestMigrationPlanPrint(t *testing.T) {
+	op := &operation{name: "empty slice"}
+	to := &opVariant{op: op, warning: "use []T{}"}
+	item := func(filename string, line int, text string, fixable bool, owners ...string) planItem {
+		it := planItem{
+			pos:    token.Position{Filename: filename, Line: line, Column: 2},
+			text:   text,
+			owners: owners,
+		}
+		if fixable {
+			it.edit = &textEdit{newText: "[]int{}"}
+		}
+		return it
+	}
+	p := &migrationPlan{
+		op: op,
+		to: to,
+		packages: map[string][]planItem{
+			"/repo/b": {
+				item("/repo/b/b.go", 5, "make([]int, 0)", true, "@b"),
+			},
+			"/repo/a": {
+				item("/repo/a/a.go", 3, "make([]int, 0)", true, "@a", "@b"),
+				item("/repo/a/a.go", 7, "make(S, 0)", false),
+			},
+		},
+		fixable: 2,
+	}
+
+	tests := []struct {
+		groupBy string
+		shorter bool
+		want    string
+	}{
+		{
+			shorter: true,
+			want: `empty slice: 3 candidates to migrate to "use []T{}" (2 fixable) in 2 packages
+./a: 2
+  ./a/a.go:3:2: make([]int, 0) (owners @a @b)
+  ./a/a.go:7:2: make(S, 0) (manual)
+./b: 1
+  ./b/b.go:5:2: make([]int, 0) (owners @b)
+`,
+		},
+		{
+			groupBy: groupByOwner,
+			shorter: true,
+			want: `empty slice: 3 candidates to migrate to "use []T{}" (2 fixable) in 2 packages
+@a: 1
+  ./a/a.go:3:2: make([]int, 0) (owners @a @b)
+@b: 2
+  ./a/a.go:3:2: make([]int, 0) (owners @a @b)
+  ./b/b.go:5:2: make([]int, 0) (owners @b)
+(unowned): 1
+  ./a/a.go:7:2: make(S, 0) (manual)
+`,
+		},
+		{
+			want: `empty slice: 3 candidates to migrate to "use []T{}" (2 fixable) in 2 packages
+/repo/a: 2
+  /repo/a/a.go:3:2: make([]int, 0) (owners @a @b)
+  /repo/a/a.go:7:2: make(S, 0) (manual)
+/repo/b: 1
+  /repo/b/b.go:5:2: make([]int, 0) (owners @b)
+`,
+		},
+	}
+
+	for _, test := range tests {
+		var ctxt context
+		ctxt.workDir = "/repo"
+		ctxt.flags.groupBy = test.groupBy
+		ctxt.flags.shorterErrLocation = test.shorter
+		var out strings.Builder
+		p.Print(&ctxt, &out)
+		if have := out.String(); have != test.want {
+			t.Errorf("group by %q, shorter %v:\nhave:\n%s\nwant:\n%s", test.groupBy, test.shorter, have, test.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Test files handling modes (see -tests flag).
+const (
+	testsInclude  = "include"
+	testsExclude  = "exclude"
+	testsSeparate = "separate"
+)
+
+// Every candidate belongs to exactly one voting pool.
+// Suggestions are inferred independently for every pool,
+// so the candidates are only compared against the majority of their own pool.
+//
+// By default, there is only one pool and its name is an empty string.
+
+// internPool returns an ID of the pool with the specified name,
+// creating a new pool if there is no such pool yet.
+func (ctxt *context) internPool(name string) int {
+	if id, ok := ctxt.poolIDs[name]; ok {
+		return id
+	}
+	if ctxt.poolIDs == nil {
+		ctxt.poolIDs = make(map[string]int)
+	}
+	id := len(ctxt.pools)
+	ctxt.pools = append(ctxt.pools, name)
+	ctxt.poolIDs[name] = id
+	return id
+}
+
+// maxPools is a max number of voting pools,
+// since the pool IDs are stored as uint16, see candidate.pool.
+const maxPools = math.MaxUint16 + 1
+
+// checkPools reports an error if there are more pools than the
+// candidates can refer to, so their pool IDs were truncated.
+func (ctxt *context) checkPools() error {
+	if len(ctxt.pools) > maxPools {
+		return fmt.Errorf("too many voting pools: %d, at most %d are supported", len(ctxt.pools), maxPools)
+	}
+	return nil
+}
+
+// filePool returns a voting pool ID for the specified file.
+func (ctxt *context) filePool(filename string) int {
+	var parts []string
+	if ctxt.flags.scope == scopeModule {
+		parts = append(parts, ctxt.moduleOf(filename))
+	}
+	if ctxt.flags.tests == testsSeparate && strings.HasSuffix(filename, "_test.go") {
+		parts = append(parts, "tests")
+	}
+	return ctxt.internPool(strings.Join(parts, " "))
+}
+
+// suggestedFor returns the suggested variant for the specified pool.
+func (op *operation) suggestedFor(pool int) *opVariant {
+	if pool < len(op.poolSuggested) {
+		return op.poolSuggested[pool]
+	}
+	return op.suggested
+}
+
+// poolCount returns a number of variant usages inside the specified pool.
+func (v *opVariant) poolCount(pool int) int {
+	if pool < len(v.poolCounts) {
+		return v.poolCounts[pool]
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestTestsMode(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{testsInclude, []string{
+			"alloc.go:10: use new(T) for *T allocation",
+			"alloc_test.go:6: use new(T) for *T allocation",
+			"alloc_test.go:7: use new(T) for *T allocation",
+		}},
+		{testsExclude, []string{
+			"alloc.go:10: use new(T) for *T allocation",
+		}},
+		{testsSeparate, []string{
+			"alloc.go:10: use new(T) for *T allocation",
+			"alloc_test.go:8: use &T{} for *T allocation (tests)",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.mode, func(t *testing.T) {
+			var ctxt context
+			ctxt.flags.tests = test.mode
+			analyzeTestdata(t, &ctxt, "./testdata/tests_separate")
+
+			var have []string
+			err := visitWarnings(&ctxt, func(w warning) {
+				s := fmt.Sprintf("%s:%d: %s", filepath.Base(w.pos.Filename), w.pos.Line, w.suggested.warning)
+				if w.pool != "" {
+					s += " (" + w.pool + ")"
+				}
+				have = append(have, s)
+			})
+			if err != nil {
+				t.Fatalf("visit warnings: %v", err)
+			}
+			sort.Strings(have)
+
+			if fmt.Sprint(have) != fmt.Sprint(test.want) {
+				t.Errorf("warnings mismatch:\nhave: %q\nwant: %q", have, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckPools(t *testing.T) {
+	var ctxt context
+	for i := 0; i < maxPools; i++ {
+		ctxt.internPool(fmt.Sprint(i))
+	}
+	if err := ctxt.checkPools(); err != nil {
+		t.Fatalf("%d pools: unexpected error: %v", len(ctxt.pools), err)
+	}
+	ctxt.internPool("overflow")
+	if err := ctxt.checkPools(); err == nil {
+		t.Errorf("%d pools: expected an error", len(ctxt.pools))
+	}
+}
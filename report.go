@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// reportFormats maps -format flag values to their implementations.
+//
+// Every reporter writes all warnings to w and returns
+// the number of reported warnings.
+var reportFormats = map[string]func(ctxt *context, w io.Writer) (int, error){
+	"text":       (*context).reportText,
+	"checkstyle": (*context).reportCheckstyle,
+	"junit":      (*context).reportJUnit,
+	"github":     (*context).reportGitHub,
+	"gitlab":     (*context).reportGitLab,
+	"markdown":   (*context).reportMarkdown,
+	"html":       (*context).reportHTML,
+	"json":       (*context).reportJSON,
+	"sarif":      (*context).reportSARIF,
+}
+
+func (ctxt *context) reportText(w io.Writer) (int, error) {
+	printWarning := func(warn warning) {
+		loc := warn.pos.String()
+		if ctxt.flags.shorterErrLocation {
+			loc = ctxt.shortenLocation(loc)
+		}
+		op := warn.variant.op
+		if ctxt.flags.showSeverity {
+			loc += ": " + op.severity
+		}
+		if len(ctxt.moduleDirs) > 1 && warn.module != "" {
+			fmt.Fprintf(w, "%s: %s: %s (module %s)%s\n", loc, op.name, warn.suggested.warning, warn.module, ownersSuffix(warn.owners))
+		} else {
+			fmt.Fprintf(w, "%s: %s: %s%s\n", loc, op.name, warn.suggested.warning, ownersSuffix(warn.owners))
+		}
+	}
+
+	var evidence *evidenceCollector
+	if ctxt.flags.evidence > 0 {
+		evidence = newEvidenceCollector(ctxt.flags.evidence)
+	}
+	grouped := ctxt.flags.groupBy == groupByOwner
+
+	// Evidence needs all candidates, not only the reported ones.
+	var warnings []warning
+	n := 0
+	err := visitCandidates(ctxt, func(warn warning) {
+		if evidence != nil {
+			evidence.Add(warn)
+		}
+		if !warn.reported() {
+			return
+		}
+		n++
+		if grouped {
+			warnings = append(warnings, warn)
+		} else {
+			printWarning(warn)
+		}
+	})
+	if err != nil {
+		return n, err
+	}
+
+	if grouped {
+		owners, groups := groupWarningsByOwner(warnings)
+		for _, owner := range owners {
+			ownerWarnings := groups[owner]
+			fmt.Fprintf(w, "%s: %d %s\n", owner, len(ownerWarnings), plural(len(ownerWarnings), "warning"))
+			for _, warn := range ownerWarnings {
+				printWarning(warn)
+			}
+		}
+	}
+	if evidence != nil {
+		evidence.Print(ctxt, w)
+	}
+	return n, nil
+}
+
+// collectWarnings returns all warnings as a slice.
+//
+// Unlike the visitWarnings, it keeps all of them in memory,
+// so it should only be used by the reporters that need grouping.
+func collectWarnings(ctxt *context) ([]warning, error) {
+	var warnings []warning
+	err := visitWarnings(ctxt, func(w warning) {
+		warnings = append(warnings, w)
+	})
+	return warnings, err
+}
+
+// groupWarningsByFile returns warnings grouped by their file names.
+// Files are listed in the order of their first warning.
+func groupWarningsByFile(warnings []warning) (files []string, groups map[string][]warning) {
+	groups = make(map[string][]warning)
+	for _, w := range warnings {
+		filename := w.pos.Filename
+		if _, ok := groups[filename]; !ok {
+			files = append(files, filename)
+		}
+		groups[filename] = append(groups[filename], w)
+	}
+	return files, groups
+}
+
+// relativePath returns filename relative to the working directory,
+// if it's located inside of it. Otherwise filename is returned unchanged.
+//
+// Unlike the shortenLocation, it never produces $GOPATH-like paths,
+// so the result can be consumed by the other tools.
+func (ctxt *context) relativePath(filename string) string {
+	if ctxt.workDir == "" || !filepath.IsAbs(filename) {
+		return filename
+	}
+	rel, err := filepath.Rel(ctxt.workDir, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filename
+	}
+	return filepath.ToSlash(rel)
+}
+
+// key returns an identifier-like operation name, like "empty-map".
+func (op *operation) key() string {
+	return strings.ReplaceAll(op.name, " ", "-")
+}
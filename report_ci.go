@@ -0,0 +1,134 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// See https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+func (ctxt *context) reportGitHub(w io.Writer) (int, error) {
+	n := 0
+	err := visitWarnings(ctxt, func(warn warning) {
+		n++
+		fmt.Fprintf(w, "::%s file=%s,line=%d,col=%d,title=%s::%s\n",
+			githubSeverities[warn.variant.op.severity],
+			escapeGitHubProperty(ctxt.relativePath(warn.pos.Filename)),
+			warn.pos.Line,
+			warn.pos.Column,
+			escapeGitHubProperty("go-consistent: "+warn.variant.op.name),
+			escapeGitHubData(warn.suggested.warning+ownersSuffix(warn.owners)))
+	})
+	return n, err
+}
+
+// githubSeverities maps the operation severities to the workflow commands.
+var githubSeverities = map[string]string{
+	severityError:   "error",
+	severityWarning: "warning",
+	severityInfo:    "notice",
+}
+
+func escapeGitHubData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+func escapeGitHubProperty(s string) string {
+	s = escapeGitHubData(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
+
+// gitlabSeverities maps the operation severities to the code quality report ones.
+var gitlabSeverities = map[string]string{
+	severityError:   "major",
+	severityWarning: "minor",
+	severityInfo:    "info",
+}
+
+// See https://docs.gitlab.com/ee/ci/testing/code_quality.html#implement-a-custom-tool
+type gitlabIssue struct {
+	Description string         `json:"description"`
+	CheckName   string         `json:"check_name"`
+	Fingerprint string         `json:"fingerprint"`
+	Severity    string         `json:"severity"`
+	Location    gitlabLocation `json:"location"`
+	Owners      []string       `json:"owners,omitempty"`
+}
+
+type gitlabLocation struct {
+	Path  string      `json:"path"`
+	Lines gitlabLines `json:"lines"`
+}
+
+type gitlabLines struct {
+	Begin int `json:"begin"`
+}
+
+func (ctxt *context) reportGitLab(w io.Writer) (int, error) {
+	issues := []gitlabIssue{}
+	fingerprints := fingerprinter{sources: sourceCache{overlay: ctxt.overlay}}
+	err := visitWarnings(ctxt, func(warn warning) {
+		path := ctxt.relativePath(warn.pos.Filename)
+		issues = append(issues, gitlabIssue{
+			Description: warn.variant.op.name + ": " + warn.suggested.warning,
+			CheckName:   "go-consistent." + warn.variant.op.key(),
+			Fingerprint: fingerprints.next(path, ctxt.sourceFilename(warn.pos.Filename), warn),
+			Severity:    gitlabSeverities[warn.variant.op.severity],
+			Location: gitlabLocation{
+				Path:  path,
+				Lines: gitlabLines{Begin: warn.pos.Line},
+			},
+			Owners: warn.owners,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(issues), writeJSON(w, issues)
+}
+
+// fingerprinter computes warning fingerprints that are stable across runs.
+//
+// A fingerprint depends on the file path, the operation, the variant
+// and the source line text, but not on the line number, so unrelated
+// edits that shift the lines don't change it. Identical warnings inside
+// the same file are told apart by their occurrence index.
+// The source lines are read from the filename the analysis used,
+// so the staged and stdin contents are respected.
+type fingerprinter struct {
+	seen map[string]int
+
+	sources sourceCache
+}
+
+func (fp *fingerprinter) next(path, filename string, warn warning) string {
+	if fp.seen == nil {
+		fp.seen = make(map[string]int)
+	}
+	key := strings.Join([]string{
+		path,
+		warn.variant.op.key(),
+		warn.variant.warning,
+		fp.sourceLine(filename, warn.pos.Line),
+	}, "\x00")
+	occurrence := fp.seen[key]
+	fp.seen[key]++
+
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", key, occurrence)))
+	return hex.EncodeToString(h[:])
+}
+
+// sourceLine returns a whitespace-trimmed source line text.
+// Returns an empty string if the file can't be read.
+func (fp *fingerprinter) sourceLine(filename string, line int) string {
+	lines := fp.sources.lines(filename)
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line-1])
+}
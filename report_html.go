@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+)
+
+// htmlOperation is an operation section of the HTML report.
+type htmlOperation struct {
+	Name     string
+	Key      string
+	Severity string
+	Total    int
+	Variants []*htmlVariant
+}
+
+type htmlVariant struct {
+	Warning    string
+	Count      int
+	Percent    int
+	Suggested  bool
+	Candidates []htmlCandidate
+}
+
+type htmlCandidate struct {
+	Location string
+	Owners   string
+	Warned   bool
+	Snippet  []htmlSnippetLine
+}
+
+type htmlSnippetLine struct {
+	Line   int
+	Text   string
+	Marked bool
+}
+
+// htmlSnippetContext is a number of lines printed around the candidate line.
+const htmlSnippetContext = 2
+
+// reportHTML prints a self-contained HTML page that describes
+// all collected candidates, not only the inconsistent ones.
+func (ctxt *context) reportHTML(w io.Writer) (int, error) {
+	// Every voting pool has its own suggestion,
+	// so the operations are described per pool.
+	type poolVariant struct {
+		v      *opVariant
+		poolID int
+	}
+	variantIndex := make(map[poolVariant]*htmlVariant)
+	var ops []*htmlOperation
+	for _, c := range ctxt.checkers {
+		op := c.Operation()
+		for poolID, pool := range ctxt.pools {
+			hop := &htmlOperation{Name: op.name, Key: op.key(), Severity: op.severity}
+			if pool != "" {
+				hop.Name += " (" + pool + ")"
+				hop.Key += "-" + pool
+			}
+			suggested := op.suggestedFor(poolID)
+			for _, v := range op.variants {
+				hv := &htmlVariant{Warning: v.warning, Count: v.poolCount(poolID), Suggested: v == suggested}
+				hop.Total += hv.Count
+				hop.Variants = append(hop.Variants, hv)
+				variantIndex[poolVariant{v: v, poolID: poolID}] = hv
+			}
+			for _, hv := range hop.Variants {
+				if hop.Total != 0 {
+					hv.Percent = hv.Count * 100 / hop.Total
+				}
+			}
+			ops = append(ops, hop)
+		}
+	}
+
+	sources := sourceCache{overlay: ctxt.overlay}
+	n := 0
+	err := visitCandidates(ctxt, func(warn warning) {
+		warned := warn.reported()
+		if warned {
+			n++
+		}
+		filename := ctxt.sourceFilename(warn.pos.Filename)
+		hv := variantIndex[poolVariant{v: warn.variant, poolID: warn.poolID}]
+		hv.Candidates = append(hv.Candidates, htmlCandidate{
+			Location: ctxt.relativePath(warn.pos.String()),
+			Owners:   strings.Join(warn.owners, " "),
+			Warned:   warned,
+			Snippet:  sources.snippet(filename, warn.pos.Line, htmlSnippetContext),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Don't include operations that were never used.
+	used := ops[:0]
+	for _, hop := range ops {
+		if hop.Total != 0 {
+			used = append(used, hop)
+		}
+	}
+
+	var buf bytes.Buffer
+	err = htmlReportTemplate.Execute(&buf, map[string]interface{}{
+		"Operations": used,
+		"Warnings":   n,
+	})
+	if err != nil {
+		return 0, err
+	}
+	_, err = w.Write(buf.Bytes())
+	return n, err
+}
+
+// sourceFilename returns a name of the file the warning filename
+// contents should be read from.
+// Warnings refer to the stdin file by its -stdin-filename, see visitCandidates.
+func (ctxt *context) sourceFilename(filename string) string {
+	if ctxt.stdinFile != "" && filename == ctxt.flags.stdinFilename {
+		return ctxt.stdinFile
+	}
+	return filename
+}
+
+// sourceCache reads source files on demand to produce code snippets.
+type sourceCache struct {
+	files map[string][]string
+
+	// overlay contents are used instead of the file system ones, see context.overlay.
+	overlay map[string][]byte
+}
+
+// lines returns the filename lines.
+// Returns nil if the file can't be read.
+func (sc *sourceCache) lines(filename string) []string {
+	if sc.files == nil {
+		sc.files = make(map[string][]string)
+	}
+	lines, ok := sc.files[filename]
+	if !ok {
+		data, ok := sc.overlay[filename]
+		if !ok {
+			var err error
+			data, err = os.ReadFile(filename)
+			if err != nil {
+				data = nil
+			}
+		}
+		if data != nil {
+			lines = splitLines(data)
+		}
+		sc.files[filename] = lines
+	}
+	return lines
+}
+
+func (sc *sourceCache) snippet(filename string, line, context int) []htmlSnippetLine {
+	lines := sc.lines(filename)
+	var snippet []htmlSnippetLine
+	for i := line - context; i <= line+context; i++ {
+		if i < 1 || i > len(lines) {
+			continue
+		}
+		snippet = append(snippet, htmlSnippetLine{
+			Line:   i,
+			Text:   lines[i-1],
+			Marked: i == line,
+		})
+	}
+	return snippet
+}
+
+func splitLines(data []byte) []string {
+	var lines []string
+	for _, l := range bytes.Split(data, []byte("\n")) {
+		lines = append(lines, string(bytes.TrimRight(l, "\r")))
+	}
+	return lines
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>go-consistent report</title>
+<style>
+body { font-family: sans-serif; margin: 2em auto; max-width: 70em; color: #222; }
+h2 { border-bottom: 1px solid #ddd; padding-bottom: .2em; }
+.severity { font-size: .6em; font-weight: normal; padding: .1em .4em; border-radius: .3em; vertical-align: middle; background: #ddd; }
+.severity.error { background: #f4c7c3; }
+.severity.warning { background: #fce8b2; }
+.chart { margin: 1em 0; }
+.bar-row { display: flex; align-items: center; margin: .3em 0; }
+.bar-label { width: 30em; font-family: monospace; }
+.bar { height: 1.2em; background: #9ab; min-width: 2px; }
+.bar.suggested { background: #4a8; }
+.bar-count { margin-left: .5em; }
+details { margin: .5em 0; }
+summary { cursor: pointer; font-family: monospace; }
+.candidate { margin: .5em 0 1em 1em; }
+.candidate .loc { font-family: monospace; }
+.candidate.warned .loc { color: #b33; font-weight: bold; }
+.candidate .owners { color: #666; font-weight: normal; }
+pre { background: #f6f8fa; margin: .2em 0; padding: .4em; overflow-x: auto; }
+pre .line { display: block; }
+pre .line.marked { background: #fff3b0; }
+pre .lineno { display: inline-block; width: 4em; color: #999; user-select: none; }
+</style>
+</head>
+<body>
+<h1>go-consistent report</h1>
+<p>{{.Warnings}} inconsistent candidates found.</p>
+{{range .Operations}}
+<h2 id="{{.Key}}">{{.Name}} <span class="severity {{.Severity}}">{{.Severity}}</span></h2>
+<div class="chart">
+{{range .Variants}}<div class="bar-row">
+<span class="bar-label">{{.Warning}}{{if .Suggested}} (suggested){{end}}</span>
+<span class="bar{{if .Suggested}} suggested{{end}}" style="width: {{.Percent}}%"></span>
+<span class="bar-count">{{.Count}}</span>
+</div>
+{{end}}</div>
+{{range .Variants}}{{if .Candidates}}
+<details>
+<summary>{{.Warning}} ({{.Count}})</summary>
+{{range .Candidates}}<div class="candidate{{if .Warned}} warned{{end}}">
+<div class="loc">{{.Location}}{{if .Owners}} <span class="owners">{{.Owners}}</span>{{end}}</div>
+<pre>{{range .Snippet}}<span class="line{{if .Marked}} marked{{end}}"><span class="lineno">{{.Line}}</span>{{.Text}}</span>{{end}}</pre>
+</div>
+{{end}}</details>
+{{end}}{{end}}{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type jsonWarning struct {
+	File      string   `json:"file"`
+	Line      int      `json:"line"`
+	Column    int      `json:"column"`
+	Operation string   `json:"operation"`
+	Severity  string   `json:"severity"`
+	Message   string   `json:"message"`
+	Module    string   `json:"module,omitempty"`
+	Owners    []string `json:"owners,omitempty"`
+}
+
+func (ctxt *context) reportJSON(w io.Writer) (int, error) {
+	warnings := []jsonWarning{}
+	err := visitWarnings(ctxt, func(warn warning) {
+		warnings = append(warnings, jsonWarning{
+			File:      ctxt.relativePath(warn.pos.Filename),
+			Line:      warn.pos.Line,
+			Column:    warn.pos.Column,
+			Operation: warn.variant.op.key(),
+			Severity:  warn.variant.op.severity,
+			Message:   warn.variant.op.name + ": " + warn.suggested.warning,
+			Module:    warn.module,
+			Owners:    warn.owners,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(warnings), writeJSON(w, warnings)
+}
+
+// sarifLevels maps the operation severities to the SARIF result levels.
+var sarifLevels = map[string]string{
+	severityError:   "error",
+	severityWarning: "warning",
+	severityInfo:    "note",
+}
+
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string           `json:"ruleId"`
+	RuleIndex  int              `json:"ruleIndex"`
+	Level      string           `json:"level"`
+	Message    sarifMessage     `json:"message"`
+	Locations  []sarifLocation  `json:"locations"`
+	Properties *sarifProperties `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+}
+
+type sarifProperties struct {
+	Owners []string `json:"owners"`
+}
+
+func (ctxt *context) reportSARIF(w io.Writer) (int, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "go-consistent",
+			InformationURI: "https://github.com/quasilyte/go-consistent",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	ruleIndex := make(map[*operation]int)
+	for i, c := range ctxt.checkers {
+		op := c.Operation()
+		ruleIndex[op] = i
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+			ID:                   op.key(),
+			ShortDescription:     sarifMessage{Text: op.name},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevels[op.severity]},
+		})
+	}
+
+	err := visitWarnings(ctxt, func(warn warning) {
+		op := warn.variant.op
+		result := sarifResult{
+			RuleID:    op.key(),
+			RuleIndex: ruleIndex[op],
+			Level:     sarifLevels[op.severity],
+			Message:   sarifMessage{Text: op.name + ": " + warn.suggested.warning},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: ctxt.relativePath(warn.pos.Filename)},
+					Region: sarifRegion{
+						StartLine:   warn.pos.Line,
+						StartColumn: warn.pos.Column,
+					},
+				},
+			}},
+		}
+		if len(warn.owners) != 0 {
+			result.Properties = &sarifProperties{Owners: warn.owners}
+		}
+		run.Results = append(run.Results, result)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	report := sarifReport{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+	return len(run.Results), writeJSON(w, report)
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reportMarkdown prints a summary that is suitable for the pull request comments.
+//
+// It consists of the operations table (with variant counts and suggestions
+// of every voting pool) and a collapsible warnings list for every file.
+func (ctxt *context) reportMarkdown(w io.Writer) (int, error) {
+	warnings, err := collectWarnings(ctxt)
+	if err != nil {
+		return 0, err
+	}
+	files, groups := groupWarningsByFile(warnings)
+
+	var buf strings.Builder
+	buf.WriteString("## go-consistent report\n\n")
+	if len(warnings) == 0 {
+		buf.WriteString("No inconsistencies found.\n\n")
+	} else {
+		fmt.Fprintf(&buf, "Found %d %s in %d %s.\n\n",
+			len(warnings), plural(len(warnings), "inconsistency"),
+			len(files), plural(len(files), "file"))
+	}
+
+	buf.WriteString("| Operation | Severity | Variants | Suggested |\n")
+	buf.WriteString("|---|---|---|---|\n")
+	for _, c := range ctxt.checkers {
+		op := c.Operation()
+		// Every voting pool has its own suggestion, so it gets its own row.
+		for poolID, pool := range ctxt.pools {
+			total := 0
+			for _, v := range op.variants {
+				total += v.poolCount(poolID)
+			}
+			if total == 0 {
+				continue
+			}
+			name := op.name
+			if pool != "" {
+				name += " (" + escapeMarkdownCell(pool) + ")"
+			}
+			var variants []string
+			for _, v := range op.variants {
+				variants = append(variants, fmt.Sprintf("%s: %d", escapeMarkdownCell(v.warning), v.poolCount(poolID)))
+			}
+			fmt.Fprintf(&buf, "| %s | %s | %s | %s |\n",
+				name, op.severity, strings.Join(variants, "<br>"), escapeMarkdownCell(op.suggestedFor(poolID).warning))
+		}
+	}
+
+	if ctxt.flags.groupBy == groupByOwner && len(warnings) != 0 {
+		owners, ownerGroups := groupWarningsByOwner(warnings)
+		buf.WriteString("\n### Owners\n\n")
+		buf.WriteString("| Owner | Warnings |\n")
+		buf.WriteString("|---|---|\n")
+		for _, owner := range owners {
+			fmt.Fprintf(&buf, "| %s | %d |\n", escapeMarkdownCell(owner), len(ownerGroups[owner]))
+		}
+	}
+
+	if len(files) != 0 {
+		buf.WriteString("\n### Warnings\n")
+	}
+	root := ctxt.repoRoot()
+	for _, filename := range files {
+		path := filename
+		if rel, err := filepath.Rel(root, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			path = filepath.ToSlash(rel)
+		}
+		fileWarnings := groups[filename]
+		fmt.Fprintf(&buf, "\n<details>\n<summary><code>%s</code> (%d)</summary>\n\n", path, len(fileWarnings))
+		for _, warn := range fileWarnings {
+			fmt.Fprintf(&buf, "- [%s:%d:%d](%s#L%d) %s: %s: %s%s\n",
+				path, warn.pos.Line, warn.pos.Column, path, warn.pos.Line,
+				warn.variant.op.severity, warn.variant.op.name, warn.suggested.warning,
+				ownersSuffix(warn.owners))
+		}
+		buf.WriteString("\n</details>\n")
+	}
+
+	_, err = io.WriteString(w, buf.String())
+	return len(warnings), err
+}
+
+// repoRoot returns the closest working directory parent that
+// contains the VCS metadata directory.
+// If there is no such directory, the working directory is returned.
+func (ctxt *context) repoRoot() string {
+	for dir := ctxt.workDir; dir != ""; {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return ctxt.workDir
+}
+
+func escapeMarkdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runReport analyzes the testdata path and returns the report
+// that was produced in the specified format.
+// want is the expected number of the reported warnings.
+func runReport(t *testing.T, ctxt *context, path, format string, want int) string {
+	t.Helper()
+	analyzeTestdata(t, ctxt, path)
+
+	var buf bytes.Buffer
+	n, err := reportFormats[format](ctxt, &buf)
+	if err != nil {
+		t.Fatalf("%s report: %v", format, err)
+	}
+	if n != want {
+		t.Errorf("%s report: have %d warnings, want %d", format, n, want)
+	}
+	return buf.String()
+}
+
+func TestReportCheckstyle(t *testing.T) {
+	var ctxt context
+	out := runReport(t, &ctxt, "./testdata/severities", "checkstyle", 2)
+
+	var report checkstyleReport
+	if err := xml.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	if len(report.Files) != 1 {
+		t.Fatalf("have %d files, want 1:\n%s", len(report.Files), out)
+	}
+	var have []string
+	for _, e := range report.Files[0].Errors {
+		have = append(have, fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Severity, e.Source))
+	}
+	want := []string{
+		"10:6: error: go-consistent.zero-value-ptr-alloc",
+		"23:1: warning: go-consistent.label-case",
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("errors mismatch:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestReportJUnit(t *testing.T) {
+	var ctxt context
+	out := runReport(t, &ctxt, "./testdata/tests_separate", "junit", 3)
+
+	var report junitTestSuites
+	if err := xml.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	if report.Failures != 1 || report.Tests != len(ctxt.checkers) {
+		t.Errorf("have %d/%d failures/tests, want 1/%d", report.Failures, report.Tests, len(ctxt.checkers))
+	}
+	for _, tc := range report.Suites[0].Cases {
+		if tc.Failure == nil {
+			continue
+		}
+		if tc.Name != "zero value ptr alloc" {
+			t.Errorf("unexpected failure for %q", tc.Name)
+		}
+		if lines := strings.Count(tc.Failure.Text, "\n"); lines != 3 {
+			t.Errorf("have %d failure lines, want 3:\n%s", lines, tc.Failure.Text)
+		}
+	}
+
+	// Every operation with warnings is a separate failure.
+	ctxt = context{}
+	out = runReport(t, &ctxt, "./testdata/severities", "junit", 2)
+	report = junitTestSuites{}
+	if err := xml.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	failures := make(map[string]string)
+	for _, tc := range report.Suites[0].Cases {
+		if tc.Failure != nil {
+			failures[tc.Name] = tc.Failure.Type
+		}
+	}
+	if len(failures) != 2 || failures["zero value ptr alloc"] != severityError || failures["label case"] != severityWarning {
+		t.Errorf("unexpected failures: %v", failures)
+	}
+}
+
+func TestReportGitHub(t *testing.T) {
+	var ctxt context
+	ctxt.workDir = "/"
+	ctxt.flags.severity = "label-case=info"
+	out := runReport(t, &ctxt, "./testdata/severities", "github", 2)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	wantPrefixes := []string{
+		"::error file=",
+		"::notice file=",
+	}
+	wantTitles := []string{
+		",title=go-consistent%3A zero value ptr alloc::",
+		",title=go-consistent%3A label case::",
+	}
+	if len(lines) != len(wantPrefixes) {
+		t.Fatalf("have %d lines, want %d:\n%s", len(lines), len(wantPrefixes), out)
+	}
+	for i, line := range lines {
+		if !strings.HasPrefix(line, wantPrefixes[i]) {
+			t.Errorf("unexpected line: %q", line)
+		}
+		if !strings.Contains(line, wantTitles[i]) {
+			t.Errorf("bad title: %q", line)
+		}
+	}
+
+	if have := escapeGitHubProperty("a:b,c%d\ne"); have != "a%3Ab%2Cc%25d%0Ae" {
+		t.Errorf("escape property: have %q", have)
+	}
+}
+
+func TestReportGitLab(t *testing.T) {
+	var ctxt context
+	out := runReport(t, &ctxt, "./testdata/severities", "gitlab", 2)
+
+	var issues []gitlabIssue
+	if err := json.Unmarshal([]byte(out), &issues); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	fingerprints := make(map[string]bool)
+	var severities []string
+	for _, issue := range issues {
+		if fingerprints[issue.Fingerprint] {
+			t.Errorf("duplicated fingerprint: %s", issue.Fingerprint)
+		}
+		fingerprints[issue.Fingerprint] = true
+		severities = append(severities, issue.Severity)
+	}
+	if strings.Join(severities, " ") != "major minor" {
+		t.Errorf("have %q severities, want major and minor", severities)
+	}
+
+	// Fingerprints must be the same for the same code.
+	var ctxt2 context
+	if out2 := runReport(t, &ctxt2, "./testdata/severities", "gitlab", 2); out2 != out {
+		t.Errorf("reports differ between runs:\n%s\n%s", out, out2)
+	}
+
+	// The source lines are read from stdin, not from the file system.
+	filename := "./testdata/severities/severities.go"
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdinIssues := func(src string) []gitlabIssue {
+		var ctxt context
+		if err := ctxt.setStdinFile(filename, strings.NewReader(src)); err != nil {
+			t.Fatalf("set stdin file: %v", err)
+		}
+		ctxt.flags.stdinFilename = filename
+		out := runReport(t, &ctxt, "./testdata/severities", "gitlab", 2)
+		var issues []gitlabIssue
+		if err := json.Unmarshal([]byte(out), &issues); err != nil {
+			t.Fatalf("unmarshal: %v\n%s", err, out)
+		}
+		return issues
+	}
+	issues = stdinIssues(string(data))
+	changed := stdinIssues(strings.Replace(string(data), "\t_ = &T{}\n", "\t_ = &T{} // stdin\n", 1))
+	if changed[0].Fingerprint == issues[0].Fingerprint {
+		t.Errorf("changed line fingerprint is the same as the original one: %s", issues[0].Fingerprint)
+	}
+	if changed[1].Fingerprint != issues[1].Fingerprint {
+		t.Errorf("unchanged line fingerprint differs: have %s, want %s", changed[1].Fingerprint, issues[1].Fingerprint)
+	}
+}
+
+func TestReportMarkdown(t *testing.T) {
+	var ctxt context
+	ctxt.workDir = "/"
+	out := runReport(t, &ctxt, "./testdata/tests_separate", "markdown", 3)
+
+	wantLines := []string{
+		"Found 3 inconsistencies in 2 files.",
+		"| zero value ptr alloc | error | use new(T) for *T allocation: 4<br>use &T{} for *T allocation: 3 | use new(T) for *T allocation |",
+		"<summary><code>",
+	}
+	for _, want := range wantLines {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q line in the report:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "<details>"); n != 2 {
+		t.Errorf("have %d details blocks, want 2:\n%s", n, out)
+	}
+
+	// Pools disagree, so every pool has its own counts and suggestion.
+	ctxt = context{}
+	ctxt.workDir = "/"
+	ctxt.flags.tests = testsSeparate
+	out = runReport(t, &ctxt, "./testdata/tests_separate", "markdown", 2)
+	wantLines = []string{
+		"Found 2 inconsistencies in 2 files.",
+		"| zero value ptr alloc | error | use new(T) for *T allocation: 3<br>use &T{} for *T allocation: 1 | use new(T) for *T allocation |",
+		"| zero value ptr alloc (tests) | error | use new(T) for *T allocation: 1<br>use &T{} for *T allocation: 2 | use &T{} for *T allocation |",
+	}
+	for _, want := range wantLines {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q line in the report:\n%s", want, out)
+		}
+	}
+}
+
+func TestReportHTML(t *testing.T) {
+	var ctxt context
+	out := runReport(t, &ctxt, "./testdata/tests_separate", "html", 3)
+
+	wantParts := []string{
+		`<h2 id="zero-value-ptr-alloc">zero value ptr alloc <span class="severity error">error</span></h2>`,
+		`style="width: 57%"`,
+		`style="width: 42%"`,
+		`<summary>use new(T) for *T allocation (4)</summary>`,
+		`<summary>use &amp;T{} for *T allocation (3)</summary>`,
+		`<span class="line marked"><span class="lineno">10</span>	_ = &amp;T{}</span>`,
+	}
+	for _, want := range wantParts {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in the report", want)
+		}
+	}
+	if n := strings.Count(out, `class="candidate warned"`); n != 3 {
+		t.Errorf("have %d warned candidates, want 3", n)
+	}
+	if strings.Contains(out, "http://") || strings.Contains(out, "https://") {
+		t.Errorf("report should not reference external assets")
+	}
+
+	// Pools disagree, so every pool has its own section.
+	ctxt = context{}
+	ctxt.flags.tests = testsSeparate
+	out = runReport(t, &ctxt, "./testdata/tests_separate", "html", 2)
+	wantSections := [][]string{
+		{
+			`<h2 id="zero-value-ptr-alloc">zero value ptr alloc <span class="severity error">error</span></h2>`,
+			`<span class="bar suggested" style="width: 75%"></span>`,
+			`<span class="bar" style="width: 25%"></span>`,
+			`<summary>use new(T) for *T allocation (3)</summary>`,
+			`<summary>use &amp;T{} for *T allocation (1)</summary>`,
+		},
+		{
+			`<h2 id="zero-value-ptr-alloc-tests">zero value ptr alloc (tests) <span class="severity error">error</span></h2>`,
+			`<span class="bar" style="width: 33%"></span>`,
+			`<span class="bar suggested" style="width: 66%"></span>`,
+			`<summary>use new(T) for *T allocation (1)</summary>`,
+			`<summary>use &amp;T{} for *T allocation (2)</summary>`,
+		},
+	}
+	sections := make(map[string]string)
+	for _, section := range strings.Split(out, "<h2 ")[1:] {
+		header := "<h2 " + section[:strings.Index(section, "\n")]
+		sections[header] = section
+	}
+	for _, wantParts := range wantSections {
+		section, ok := sections[wantParts[0]]
+		if !ok {
+			t.Errorf("missing %q section", wantParts[0])
+			continue
+		}
+		for _, want := range wantParts[1:] {
+			if !strings.Contains(section, want) {
+				t.Errorf("%s: missing %q", wantParts[0], want)
+			}
+		}
+	}
+	if n := strings.Count(out, `class="candidate warned"`); n != 2 {
+		t.Errorf("have %d warned candidates, want 2", n)
+	}
+}
+
+func TestReportTextEvidence(t *testing.T) {
+	var ctxt context
+	ctxt.flags.evidence = 2
+	out := runReport(t, &ctxt, "./testdata/tests_separate", "text", 3)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("have %d lines, want 6:\n%s", len(lines), out)
+	}
+	want := "evidence: zero value ptr alloc: use new(T) for *T allocation: 4 of 7 usages"
+	if lines[3] != want {
+		t.Errorf("evidence header mismatch:\nhave: %s\nwant: %s", lines[3], want)
+	}
+	for _, l := range lines[4:] {
+		if !strings.HasPrefix(l, "\t") || !strings.Contains(l, "alloc") {
+			t.Errorf("unexpected evidence location: %q", l)
+		}
+	}
+}
+
+func TestReportHTMLStaged(t *testing.T) {
+	filename, err := filepath.Abs("testdata/tests_separate/alloc.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the alloc.go:10 warning is staged, so every format
+	// should agree that there is exactly one warning.
+	for _, format := range []string{"text", "html"} {
+		var ctxt context
+		ctxt.staged = &stagedChanges{
+			lines: map[string][]lineRange{filename: {{from: 10, to: 10}}},
+		}
+		out := runReport(t, &ctxt, "./testdata/tests_separate", format, 1)
+		if format == "html" {
+			if n := strings.Count(out, `class="candidate warned"`); n != 1 {
+				t.Errorf("have %d warned candidates, want 1", n)
+			}
+		}
+	}
+}
+
+func TestReportTextEvidenceGroupByOwner(t *testing.T) {
+	root, err := filepath.Abs("testdata/tests_separate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	co, err := parseCodeOwners(strings.NewReader("* @team\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	co.root = root
+
+	var ctxt context
+	ctxt.codeowners = co
+	ctxt.flags.groupBy = groupByOwner
+	ctxt.flags.evidence = 2
+	out := runReport(t, &ctxt, "./testdata/tests_separate", "text", 3)
+
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("have %d lines, want 7:\n%s", len(lines), out)
+	}
+	if lines[0] != "@team: 3 warnings" {
+		t.Errorf("group header mismatch: %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[4], "evidence: zero value ptr alloc:") {
+		t.Errorf("evidence header mismatch: %q", lines[4])
+	}
+}
+
+func TestReportTextSeverity(t *testing.T) {
+	var ctxt context
+	out := runReport(t, &ctxt, "./testdata/severities", "text", 2)
+	if strings.Contains(out, ": error:") || strings.Contains(out, ": warning:") {
+		t.Errorf("default output contains severity:\n%s", out)
+	}
+
+	// Severity overrides don't change the output format.
+	ctxt = context{}
+	ctxt.flags.severity = "label-case=info"
+	out2 := runReport(t, &ctxt, "./testdata/severities", "text", 2)
+	if out2 != out {
+		t.Errorf("severity overrides changed the output:\n%s\n%s", out, out2)
+	}
+
+	ctxt = context{}
+	ctxt.flags.severity = "label-case=info"
+	ctxt.flags.showSeverity = true
+	out = runReport(t, &ctxt, "./testdata/severities", "text", 2)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	want := []string{
+		": error: zero value ptr alloc: ",
+		": info: label case: ",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("have %d lines, want %d:\n%s", len(lines), len(want), out)
+	}
+	for i, l := range lines {
+		if !strings.Contains(l, want[i]) {
+			t.Errorf("severity mismatch: %q doesn't contain %q", l, want[i])
+		}
+	}
+}
+
+func TestReportJSON(t *testing.T) {
+	var ctxt context
+	ctxt.workDir = "/"
+	ctxt.flags.severity = "zero-value-ptr-alloc=info"
+	out := runReport(t, &ctxt, "./testdata/severities", "json", 2)
+
+	var warnings []jsonWarning
+	if err := json.Unmarshal([]byte(out), &warnings); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	var have []string
+	for _, w := range warnings {
+		have = append(have, fmt.Sprintf("%d:%d: %s: %s: %s", w.Line, w.Column, w.Severity, w.Operation, w.Message))
+	}
+	want := []string{
+		"10:6: info: zero-value-ptr-alloc: zero value ptr alloc: use new(T) for *T allocation",
+		"23:1: warning: label-case: label case: use UpperCamelCase",
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("warnings mismatch:\nhave: %q\nwant: %q", have, want)
+	}
+}
+
+func TestReportSARIF(t *testing.T) {
+	var ctxt context
+	ctxt.flags.severity = "zero-value-ptr-alloc=info"
+	out := runReport(t, &ctxt, "./testdata/severities", "sarif", 2)
+
+	var report sarifReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, out)
+	}
+	if report.Version != "2.1.0" || len(report.Runs) != 1 {
+		t.Fatalf("unexpected report:\n%s", out)
+	}
+	run := report.Runs[0]
+	if len(run.Tool.Driver.Rules) != len(ctxt.checkers) {
+		t.Errorf("have %d rules, want %d", len(run.Tool.Driver.Rules), len(ctxt.checkers))
+	}
+	var have []string
+	for _, r := range run.Results {
+		rule := run.Tool.Driver.Rules[r.RuleIndex]
+		if rule.ID != r.RuleID || rule.DefaultConfiguration.Level != r.Level {
+			t.Errorf("%s: result doesn't match the %s rule", r.RuleID, rule.ID)
+		}
+		if len(r.Locations) != 1 {
+			t.Fatalf("%s: have %d locations, want 1", r.RuleID, len(r.Locations))
+		}
+		region := r.Locations[0].PhysicalLocation.Region
+		have = append(have, fmt.Sprintf("%d:%d: %s: %s", region.StartLine, region.StartColumn, r.Level, r.RuleID))
+	}
+	want := []string{
+		"10:6: note: zero-value-ptr-alloc",
+		"23:1: warning: label-case",
+	}
+	if strings.Join(have, "\n") != strings.Join(want, "\n") {
+		t.Errorf("results mismatch:\nhave: %q\nwant: %q", have, want)
+	}
+}
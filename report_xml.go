@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// See https://checkstyle.sourceforge.io/ for the format description.
+type checkstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []checkstyleFile `xml:"file"`
+}
+
+type checkstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []checkstyleError `xml:"error"`
+}
+
+type checkstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+	Owners   string `xml:"owners,attr,omitempty"`
+}
+
+func (ctxt *context) reportCheckstyle(w io.Writer) (int, error) {
+	warnings, err := collectWarnings(ctxt)
+	if err != nil {
+		return 0, err
+	}
+
+	report := checkstyleReport{Version: "5.0"}
+	files, groups := groupWarningsByFile(warnings)
+	for _, filename := range files {
+		f := checkstyleFile{Name: ctxt.relativePath(filename)}
+		for _, warn := range groups[filename] {
+			f.Errors = append(f.Errors, checkstyleError{
+				Line:     warn.pos.Line,
+				Column:   warn.pos.Column,
+				Severity: warn.variant.op.severity,
+				Message:  warn.variant.op.name + ": " + warn.suggested.warning,
+				Source:   "go-consistent." + warn.variant.op.key(),
+				Owners:   strings.Join(warn.owners, " "),
+			})
+		}
+		report.Files = append(report.Files, f)
+	}
+
+	return len(warnings), writeXML(w, report)
+}
+
+// See https://github.com/testmoapp/junitxml for the format description.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+func (ctxt *context) reportJUnit(w io.Writer) (int, error) {
+	warnings, err := collectWarnings(ctxt)
+	if err != nil {
+		return 0, err
+	}
+
+	byOp := make(map[*operation][]warning)
+	for _, warn := range warnings {
+		byOp[warn.variant.op] = append(byOp[warn.variant.op], warn)
+	}
+
+	// Every operation is a test case that fails if there are any inconsistencies.
+	suite := junitTestSuite{Name: "go-consistent"}
+	for _, c := range ctxt.checkers {
+		op := c.Operation()
+		tc := junitTestCase{Name: op.name, Classname: "go-consistent." + op.key()}
+		if opWarnings := byOp[op]; len(opWarnings) != 0 {
+			var text strings.Builder
+			for _, warn := range opWarnings {
+				fmt.Fprintf(&text, "%s:%d:%d: %s%s\n",
+					ctxt.relativePath(warn.pos.Filename), warn.pos.Line, warn.pos.Column, warn.suggested.warning,
+					ownersSuffix(warn.owners))
+			}
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d inconsistent %s", len(opWarnings), plural(len(opWarnings), "location")),
+				Type:    op.severity,
+				Text:    text.String(),
+			}
+			suite.Failures++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	report := junitTestSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}
+	return len(warnings), writeXML(w, report)
+}
+
+func writeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
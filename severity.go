@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operation severities.
+//
+// By default, only the errors fail the check (see -fail-on).
+const (
+	severityError   = "error"
+	severityWarning = "warning"
+	severityInfo    = "info"
+)
+
+func isValidSeverity(s string) bool {
+	switch s {
+	case severityError, severityWarning, severityInfo:
+		return true
+	default:
+		return false
+	}
+}
+
+// applySeverities parses the -severity flag value,
+// like "hex-lit=warning,label-case=error", and overrides
+// the default severities of the specified operations.
+func applySeverities(s string, checkers []checker) error {
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, severity, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("%q: expected op=severity pair", pair)
+		}
+		if !isValidSeverity(severity) {
+			return fmt.Errorf("%q: unexpected severity %q", pair, severity)
+		}
+		ops, err := parseOperationList(name, checkers)
+		if err != nil {
+			return err
+		}
+		for op := range ops {
+			op.severity = severity
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// globList is a list of shell file name patterns, as understood by filepath.Match.
+type globList []string
+
+// parseGlobList parses a comma-separated list of glob patterns.
+func parseGlobList(s string) (globList, error) {
+	var list globList
+	for _, pattern := range strings.Split(s, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%q: %w", pattern, err)
+		}
+		list = append(list, filepath.ToSlash(pattern))
+	}
+	return list, nil
+}
+
+// match returns the first pattern that matches s.
+func (list globList) match(s string) (string, bool) {
+	for _, pattern := range list {
+		if ok, _ := filepath.Match(pattern, s); ok {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// skipReason reports why the file should not be analyzed.
+// Returns an empty string if it should be analyzed.
+//
+// Patterns from -skip-files are matched against the file base name
+// and against the slash-separated file path relative to the working directory.
+// Patterns from -skip-dirs are matched against every directory
+// component of that relative path.
+func (ctxt *context) skipReason(filename string) string {
+	if len(ctxt.skipFiles) == 0 && len(ctxt.skipDirs) == 0 {
+		return ""
+	}
+
+	relname := filename
+	if ctxt.workDir != "" {
+		if rel, err := filepath.Rel(ctxt.workDir, filename); err == nil {
+			relname = rel
+		}
+	}
+	relname = filepath.ToSlash(relname)
+
+	if pattern, ok := ctxt.skipFiles.match(filepath.Base(filename)); ok {
+		return fmt.Sprintf("matches -skip-files %q", pattern)
+	}
+	if pattern, ok := ctxt.skipFiles.match(relname); ok {
+		return fmt.Sprintf("matches -skip-files %q", pattern)
+	}
+
+	dirs := strings.Split(relname, "/")
+	dirs = dirs[:len(dirs)-1] // Drop the file base name
+	for _, dir := range dirs {
+		if pattern, ok := ctxt.skipDirs.match(dir); ok {
+			return fmt.Sprintf("directory %q matches -skip-dirs %q", dir, pattern)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSkipReason(t *testing.T) {
+	var ctxt context
+	ctxt.workDir = "/home/gopher/project"
+	var err error
+	ctxt.skipFiles, err = parseGlobList("*_string.go, mock_*.go,internal/gen/*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctxt.skipDirs, err = parseGlobList("testdata,vendor,*mocks")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filename string
+		skip     bool
+	}{
+		{"/home/gopher/project/main.go", false},
+		{"/home/gopher/project/op_string.go", true},
+		{"/home/gopher/project/pkg/mock_db.go", true},
+		{"/home/gopher/project/internal/gen/a.go", true},
+		{"/home/gopher/project/internal/gen/sub/a.go", false},
+		{"/home/gopher/project/testdata/a.go", true},
+		{"/home/gopher/project/pkg/vendor/x/a.go", true},
+		{"/home/gopher/project/pkg/dbmocks/a.go", true},
+		{"/home/gopher/project/pkg/mocksdb/a.go", false},
+		{"/home/gopher/project/vendor.go", false},
+	}
+
+	for _, test := range tests {
+		reason := ctxt.skipReason(test.filename)
+		if have := reason != ""; have != test.skip {
+			t.Errorf("skipReason(%q): have %q, want skip=%v", test.filename, reason, test.skip)
+		}
+	}
+
+	if _, err := parseGlobList("a,[b"); err == nil {
+		t.Errorf("expected an error for malformed pattern")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// The -staged mode is intended to be used from a git pre-commit hook.
+//
+// All files are analyzed the way they are recorded in the git index:
+// files with unstaged changes are read from the index via the overlay
+// and untracked files are skipped. The whole project still votes,
+// but only the lines that are a part of the staged hunks are reported.
+
+// stagedChanges describes the git index state for the -staged mode.
+type stagedChanges struct {
+	// lines maps absolute file names to their staged line ranges.
+	lines map[string][]lineRange
+
+	// untracked is a set of absolute file names that are not in the index.
+	untracked map[string]bool
+}
+
+// lineRange is an inclusive range of line numbers.
+type lineRange struct {
+	from int
+	to   int
+}
+
+// contains reports whether the line of the filename is staged.
+func (s *stagedChanges) contains(filename string, line int) bool {
+	for _, r := range s.lines[filename] {
+		if line >= r.from && line <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// loadStaged reads the git index state and makes
+// the analysis use the staged files versions.
+func (ctxt *context) loadStaged() error {
+	root, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	diff, err := gitOutput("-C", root, "diff", "--cached", "-U0", "--no-color", "--no-ext-diff",
+		"--diff-filter=ACMR", "--", "*.go")
+	if err != nil {
+		return err
+	}
+	lines, err := parseStagedHunks(root, diff)
+	if err != nil {
+		return err
+	}
+	ctxt.staged = &stagedChanges{
+		lines:     lines,
+		untracked: make(map[string]bool),
+	}
+
+	untracked, err := gitOutput("-C", root, "ls-files", "-z", "--others", "--exclude-standard", "--", "*.go")
+	if err != nil {
+		return err
+	}
+	for _, name := range splitNul(untracked) {
+		ctxt.staged.untracked[filepath.Join(root, name)] = true
+	}
+
+	// Files with the unstaged changes (or even deleted ones)
+	// are analyzed the way they're recorded in the index.
+	unstaged, err := gitOutput("-C", root, "diff", "-z", "--name-only", "--no-renames", "--", "*.go")
+	if err != nil {
+		return err
+	}
+	for _, name := range splitNul(unstaged) {
+		data, err := gitRawOutput("-C", root, "show", ":"+name)
+		if err != nil {
+			// Not in the index, there is nothing to analyze.
+			continue
+		}
+		if ctxt.overlay == nil {
+			ctxt.overlay = make(map[string][]byte)
+		}
+		ctxt.overlay[filepath.Join(root, name)] = data
+	}
+
+	if len(ctxt.flags.targets) == 0 {
+		// Let the whole project vote.
+		ctxt.flags.targets = []string{filepath.Join(root, "...")}
+	}
+	return nil
+}
+
+// parseStagedHunks parses the zero context diff and
+// returns the added lines ranges for every file.
+func parseStagedHunks(root, diff string) (map[string][]lineRange, error) {
+	lines := make(map[string][]lineRange)
+	filename := ""
+	header := false
+	s := bufio.NewScanner(strings.NewReader(diff))
+	s.Buffer(nil, 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			header = true
+		case header && strings.HasPrefix(line, "+++ "):
+			name := strings.TrimPrefix(line, "+++ ")
+			if unquoted, err := strconv.Unquote(name); err == nil {
+				name = unquoted
+			}
+			filename = ""
+			if strings.HasPrefix(name, "b/") {
+				filename = filepath.Join(root, filepath.FromSlash(name[len("b/"):]))
+			}
+		case strings.HasPrefix(line, "@@ "):
+			header = false
+			if filename == "" {
+				continue
+			}
+			// @@ -from[,count] +from[,count] @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 || !strings.HasPrefix(fields[2], "+") {
+				return nil, fmt.Errorf("unexpected hunk header: %q", line)
+			}
+			from, count, err := parseHunkRange(fields[2][len("+"):])
+			if err != nil {
+				return nil, fmt.Errorf("hunk header %q: %w", line, err)
+			}
+			if count == 0 {
+				continue // Only deletions
+			}
+			lines[filename] = append(lines[filename], lineRange{from: from, to: from + count - 1})
+		}
+	}
+	return lines, s.Err()
+}
+
+func parseHunkRange(s string) (from, count int, err error) {
+	count = 1
+	fromString, countString, ok := strings.Cut(s, ",")
+	if ok {
+		count, err = strconv.Atoi(countString)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	from, err = strconv.Atoi(fromString)
+	return from, count, err
+}
+
+func splitNul(s string) []string {
+	var list []string
+	for _, name := range strings.Split(s, "\x00") {
+		if name != "" {
+			list = append(list, name)
+		}
+	}
+	return list
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStagedHunks(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -3,0 +4,2 @@ package a
++var _ = map[int]int{}
+++++ not a header
+@@ -10 +12 @@ func f() {
+-	return 1
++	return 2
+@@ -20,3 +23,0 @@ func g() {
+-	x := 1
+-	y := 2
+-	z := 3
+diff --git "a/sub/b\tc.go" "b/sub/b\tc.go"
+new file mode 100644
+--- /dev/null
++++ "b/sub/b\tc.go"
+@@ -0,0 +1,3 @@
++package sub
++
++var _ = 0x1
+`
+	have, err := parseStagedHunks("/repo", diff)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := map[string][]lineRange{
+		"/repo/a.go":        {{from: 4, to: 5}, {from: 12, to: 12}},
+		"/repo/sub/b\tc.go": {{from: 1, to: 3}},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("hunks mismatch:\nhave: %v\nwant: %v", have, want)
+	}
+
+	staged := stagedChanges{lines: have}
+	tests := []struct {
+		filename string
+		line     int
+		want     bool
+	}{
+		{"/repo/a.go", 3, false},
+		{"/repo/a.go", 4, true},
+		{"/repo/a.go", 5, true},
+		{"/repo/a.go", 12, true},
+		{"/repo/a.go", 23, false},
+		{"/repo/other.go", 1, false},
+	}
+	for _, test := range tests {
+		if have := staged.contains(test.filename, test.line); have != test.want {
+			t.Errorf("contains(%s, %d): have %v, want %v", test.filename, test.line, have, test.want)
+		}
+	}
+}
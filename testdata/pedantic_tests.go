@@ -0,0 +1,39 @@
+package pedantic
+
+// In this test suite, the -pedantic mode is enabled.
+
+// T is an example type.
+type T struct{}
+
+func zeroValPtrAlloc() {
+	// Basic types can't use &T{}, so they don't vote.
+	_ = new(int)
+	_ = new(string)
+	_ = new(bool)
+	_ = &T{}
+	_ = &T{}
+	//= zero value ptr alloc: use &T{} for *T allocation
+	_ = new(T)
+}
+
+func hexLit() {
+	_ = 0xff
+	_ = 0xabcdef
+	// Mixed-case literals don't vote and are always reported.
+	//= hex lit: use a-f (lower case) digits
+	_ = 0Xcd
+	//= hex lit: use a-f (lower case) digits
+	_ = 0xAbC
+	//= hex lit: use a-f (lower case) digits
+	_ = 0XFF
+}
+
+func nonZeroLenTest(s string, xs []int) {
+	_ = len(s) != 0
+	_ = len(xs) != 0
+	_ = len(xs) == 0
+	//= non-zero length test: use `len(s) != 0` and `len(s) == 0`
+	_ = len(s) < 1
+	//= non-zero length test: use `len(s) != 0` and `len(s) == 0`
+	_ = len(xs) > 0
+}
@@ -0,0 +1,27 @@
+package severities
+
+// T is an example type.
+type T struct{}
+
+// Zero value ptr alloc is an error.
+func alloc() {
+	_ = new(T)
+	_ = new(T)
+	_ = &T{}
+}
+
+// Label case is a warning.
+func labels() {
+Outer:
+	for {
+		break Outer
+	}
+Inner:
+	for {
+		break Inner
+	}
+loop:
+	for {
+		break loop
+	}
+}
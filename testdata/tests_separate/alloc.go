@@ -0,0 +1,11 @@
+package alloc
+
+// T is an example type.
+type T struct{}
+
+func production() {
+	_ = new(T)
+	_ = new(T)
+	_ = new(T)
+	_ = &T{}
+}
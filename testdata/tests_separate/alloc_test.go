@@ -0,0 +1,9 @@
+package alloc
+
+import "testing"
+
+func TestAlloc(t *testing.T) {
+	_ = &T{}
+	_ = &T{}
+	_ = new(T)
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"time"
+)
+
+// timingsTopPackages is a max number of the slowest packages
+// printed by the -timings report.
+const timingsTopPackages = 10
+
+// timings collects the -timings report data.
+//
+// All methods are nil-safe, so the calls don't need
+// to be guarded when -timings flag is not set.
+type timings struct {
+	steps    []timing
+	packages []timing
+
+	// checkers are cumulative checker Visit times, indexed like ctxt.checkers.
+	checkers []time.Duration
+}
+
+type timing struct {
+	name     string
+	duration time.Duration
+}
+
+func (t *timings) addStep(name string, d time.Duration) {
+	if t != nil {
+		t.steps = append(t.steps, timing{name: name, duration: d})
+	}
+}
+
+func (t *timings) addPackage(name string, d time.Duration) {
+	if t != nil {
+		t.packages = append(t.packages, timing{name: name, duration: d})
+	}
+}
+
+func (t *timings) addChecker(i int, d time.Duration) {
+	if t == nil {
+		return
+	}
+	for len(t.checkers) <= i {
+		t.checkers = append(t.checkers, 0)
+	}
+	t.checkers[i] += d
+}
+
+func (t *timings) Print(ctxt *context, w io.Writer) {
+	if t == nil {
+		return
+	}
+
+	fmt.Fprintf(w, "timings: steps:\n")
+	for _, s := range t.steps {
+		fmt.Fprintf(w, "  %-24s %v\n", s.name, s.duration.Round(time.Microsecond))
+	}
+
+	packages := append([]timing(nil), t.packages...)
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].duration > packages[j].duration
+	})
+	if len(packages) > timingsTopPackages {
+		fmt.Fprintf(w, "timings: packages (%d slowest of %d):\n", timingsTopPackages, len(packages))
+		packages = packages[:timingsTopPackages]
+	} else {
+		fmt.Fprintf(w, "timings: packages:\n")
+	}
+	for _, p := range packages {
+		fmt.Fprintf(w, "  %-24s %v\n", p.name, p.duration.Round(time.Microsecond))
+	}
+
+	fmt.Fprintf(w, "timings: checkers:\n")
+	for i, c := range ctxt.checkers {
+		var d time.Duration
+		if i < len(t.checkers) {
+			d = t.checkers[i]
+		}
+		fmt.Fprintf(w, "  %-24s %v\n", c.Operation().name, d.Round(time.Microsecond))
+	}
+}
+
+// startProfiling starts the CPU profiling if -cpuprofile is set.
+func (ctxt *context) startProfiling() error {
+	if ctxt.flags.cpuProfile == "" {
+		return nil
+	}
+	f, err := os.Create(ctxt.flags.cpuProfile)
+	if err != nil {
+		return err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	ctxt.cpuProfile = f
+	return nil
+}
+
+// finish stops the profiling, writes the memory profile
+// and prints the timings report.
+// It should be called right before the program exit.
+func (ctxt *context) finish() {
+	if ctxt.cpuProfile != nil {
+		pprof.StopCPUProfile()
+		if err := ctxt.cpuProfile.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "-cpuprofile: %v\n", err)
+		}
+		ctxt.cpuProfile = nil
+	}
+	if ctxt.flags.memProfile != "" {
+		if err := writeMemProfile(ctxt.flags.memProfile); err != nil {
+			fmt.Fprintf(os.Stderr, "-memprofile: %v\n", err)
+		}
+	}
+	ctxt.timings.Print(ctxt, os.Stderr)
+}
+
+func writeMemProfile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	runtime.GC() // Get up-to-date statistics
+	err = pprof.WriteHeapProfile(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"go/ast"
+	"os"
+	"strings"
 )
 
 func valueOf(x ast.Node) string {
@@ -14,3 +16,19 @@ func valueOf(x ast.Node) string {
 		return ""
 	}
 }
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
+
+func plural(n int, word string) string {
+	switch {
+	case n == 1:
+		return word
+	case strings.HasSuffix(word, "y"):
+		return word[:len(word)-1] + "ies"
+	default:
+		return word + "s"
+	}
+}
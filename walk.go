@@ -0,0 +1,108 @@
+package main
+
+import (
+	"go/ast"
+	"reflect"
+	"time"
+)
+
+// fileWalker traverses the file AST once, routing every node
+// to the checkers that are interested in its type.
+//
+// The subtree pruning works per checker: when a checker Visit
+// returns false, the other checkers still visit the node children.
+type fileWalker struct {
+	checkers []checker
+
+	// byKind maps node types to the interested checkers indexes.
+	byKind map[reflect.Type][]int
+
+	// pruned[i] is a node whose children are skipped for the i-th checker.
+	pruned  []ast.Node
+	npruned int
+
+	// stack holds the ancestors of the node that is being visited.
+	stack []ast.Node
+
+	timings *timings
+}
+
+func newFileWalker(checkers []checker, t *timings) *fileWalker {
+	w := &fileWalker{
+		checkers: checkers,
+		byKind:   make(map[reflect.Type][]int),
+		pruned:   make([]ast.Node, len(checkers)),
+		timings:  t,
+	}
+	for i, c := range checkers {
+		for _, kind := range c.NodeKinds() {
+			typ := reflect.TypeOf(kind)
+			w.byKind[typ] = append(w.byKind[typ], i)
+		}
+	}
+	return w
+}
+
+// Walk runs the checkers over all f declarations.
+func (w *fileWalker) Walk(f *ast.File) {
+	for _, decl := range f.Decls {
+		ast.Inspect(decl, w.visit)
+	}
+}
+
+func (w *fileWalker) visit(n ast.Node) bool {
+	if n == nil {
+		w.leave(w.stack[len(w.stack)-1])
+		w.stack = w.stack[:len(w.stack)-1]
+		return true
+	}
+
+	for _, i := range w.byKind[reflect.TypeOf(n)] {
+		if w.pruned[i] != nil {
+			continue
+		}
+		var start time.Time
+		if w.timings != nil {
+			start = time.Now()
+		}
+		if !w.checkers[i].Visit(n) {
+			w.pruned[i] = n
+			w.npruned++
+		}
+		if w.timings != nil {
+			w.timings.addChecker(i, time.Since(start))
+		}
+	}
+
+	if w.npruned == len(w.checkers) {
+		// Nobody is interested in the children.
+		// Since the leave callback is not called for n in this case,
+		// do it right away.
+		w.leave(n)
+		return false
+	}
+	w.stack = append(w.stack, n)
+	return true
+}
+
+// Parent returns a parent of the node that is being visited.
+// Returns nil for the top-level declarations.
+func (w *fileWalker) Parent() ast.Node {
+	if len(w.stack) == 0 {
+		return nil
+	}
+	return w.stack[len(w.stack)-1]
+}
+
+// leave re-enables the checkers that were pruned at n.
+func (w *fileWalker) leave(n ast.Node) {
+	if w.npruned == 0 {
+		return
+	}
+	for i, pruned := range w.pruned {
+		if pruned == n {
+			w.pruned[i] = nil
+			w.npruned--
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+
+	"github.com/go-toolsmith/astinfo"
+	"golang.org/x/tools/go/packages"
+)
+
+// collectFileCandidatesPerChecker is the old collectFileCandidates
+// implementation, it walks the file once per every checker,
+// calling Visit for every node, plus once more to resolve the parents.
+// It's used as a reference for the fileWalker.
+func (ctxt *context) collectFileCandidatesPerChecker(f *ast.File) {
+	info := astinfo.Info{
+		Parents: make(map[ast.Node]ast.Node),
+	}
+	info.Origin = f
+	info.Resolve()
+
+	for _, c := range ctxt.checkers {
+		for _, decl := range f.Decls {
+			ast.Inspect(decl, func(n ast.Node) bool {
+				if n == nil {
+					return true
+				}
+				// Checkers get the parent from the walker stack.
+				ctxt.walker.stack = append(ctxt.walker.stack[:0], info.Parents[n])
+				return c.Visit(n)
+			})
+		}
+	}
+	ctxt.walker.stack = ctxt.walker.stack[:0]
+}
+
+// loadWalkPackages loads the std packages for the walker tests and benchmarks.
+func loadWalkPackages(tb testing.TB, ctxt *context, patterns ...string) []*packages.Package {
+	ctxt.fset = token.NewFileSet()
+	conf := &packages.Config{
+		Mode: packages.NeedSyntax | packages.NeedName | packages.NeedFiles |
+			packages.NeedTypes | packages.NeedTypesInfo,
+		Fset: ctxt.fset,
+	}
+	pkgs, err := packages.Load(conf, patterns...)
+	if err != nil {
+		tb.Fatalf("load packages: %v", err)
+	}
+	if packages.PrintErrors(pkgs) != 0 {
+		tb.Fatalf("load packages: build errors")
+	}
+	return pkgs
+}
+
+func walkPackages(ctxt *context, pkgs []*packages.Package, collect func(f *ast.File)) {
+	for _, pkg := range pkgs {
+		ctxt.info = pkg.TypesInfo
+		for _, f := range pkg.Syntax {
+			collect(f)
+		}
+	}
+}
+
+func TestFileWalker(t *testing.T) {
+	for _, pedantic := range []bool{false, true} {
+		var ctxt context
+		ctxt.flags.pedantic = pedantic
+		_ = ctxt.initCheckers()
+		pkgs := loadWalkPackages(t, &ctxt, "strconv", "go/ast", "net/http", "text/template/parse")
+
+		collect := func(visit func(f *ast.File)) map[candidate]int {
+			ctxt.candidates.Reset()
+			walkPackages(&ctxt, pkgs, visit)
+			result := make(map[candidate]int)
+			err := ctxt.candidates.Visit(func(c candidate) {
+				result[c]++
+			})
+			if err != nil {
+				t.Fatalf("visit candidates: %v", err)
+			}
+			return result
+		}
+
+		want := collect(ctxt.collectFileCandidatesPerChecker)
+		have := collect(ctxt.collectFileCandidates)
+		if len(want) == 0 {
+			t.Fatalf("pedantic=%v: no candidates found", pedantic)
+		}
+		for c, n := range want {
+			if have[c] != n {
+				t.Errorf("pedantic=%v: %s: have %d candidates, want %d",
+					pedantic, ctxt.locs.Get(c.loc), have[c], n)
+			}
+		}
+		for c, n := range have {
+			if _, ok := want[c]; !ok {
+				t.Errorf("pedantic=%v: %s: unexpected %d candidates",
+					pedantic, ctxt.locs.Get(c.loc), n)
+			}
+		}
+	}
+}
+
+func BenchmarkFileWalker(b *testing.B) {
+	var ctxt context
+	_ = ctxt.initCheckers()
+	pkgs := loadWalkPackages(b, &ctxt, "std")
+
+	b.Run("per-checker", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			walkPackages(&ctxt, pkgs, ctxt.collectFileCandidatesPerChecker)
+			ctxt.candidates.Reset()
+		}
+	})
+	b.Run("single-walk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			walkPackages(&ctxt, pkgs, ctxt.collectFileCandidates)
+			ctxt.candidates.Reset()
+		}
+	})
+}
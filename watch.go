@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// watchInterval is a delay between the file system polls in the -watch mode.
+const watchInterval = time.Second
+
+// watchedPath is a single target path analyzed under a single build config.
+//
+// It remembers its contribution to the variant counters,
+// so the path can be re-analyzed without touching the others.
+type watchedPath struct {
+	path   targetPath
+	config buildConfig
+
+	// dirs are the directories of the loaded packages.
+	dirs []string
+
+	// stamp is a fingerprint of the dirs Go files.
+	stamp string
+
+	// files are the files analyzed for this path; see context.seenFiles.
+	files []string
+
+	// counts is a contribution of this path to the variant counters.
+	counts variantCounts
+
+	candidates candidateList
+}
+
+// variantCounts is a snapshot of the variant counters.
+// Every value holds the count followed by the poolCounts.
+type variantCounts map[*opVariant][]int
+
+func (ctxt *context) variantCounts() variantCounts {
+	counts := make(variantCounts)
+	for _, c := range ctxt.checkers {
+		for _, v := range c.Operation().variants {
+			counts[v] = append([]int{v.count}, v.poolCounts...)
+		}
+	}
+	return counts
+}
+
+// restore sets the variant counters to the snapshot values.
+func (counts variantCounts) restore() {
+	for v, saved := range counts {
+		v.count = saved[0]
+		v.poolCounts = append(v.poolCounts[:0], saved[1:]...)
+	}
+}
+
+// diff returns the counters difference between the current values and the snapshot.
+func (counts variantCounts) diff() variantCounts {
+	delta := make(variantCounts, len(counts))
+	for v, saved := range counts {
+		d := []int{v.count - saved[0]}
+		for pool := range v.poolCounts {
+			old := 0
+			if pool+1 < len(saved) {
+				old = saved[pool+1]
+			}
+			d = append(d, v.poolCounts[pool]-old)
+		}
+		delta[v] = d
+	}
+	return delta
+}
+
+// subtract removes the delta values from the variant counters.
+func (delta variantCounts) subtract() {
+	for v, d := range delta {
+		v.count -= d[0]
+		for pool, n := range d[1:] {
+			v.poolCounts[pool] -= n
+		}
+	}
+}
+
+// collectWatchedCandidates is the -watch mode version of the collectAllCandidates.
+func (ctxt *context) collectWatchedCandidates() error {
+	configs := ctxt.buildConfigs
+	if len(configs) == 0 {
+		configs = []buildConfig{{}}
+	}
+	ctxt.seenFiles = make(map[string]struct{})
+
+	ctxt.watched = ctxt.watched[:0]
+	for _, p := range ctxt.paths {
+		for _, config := range configs {
+			wp := &watchedPath{path: p, config: config}
+			wp.candidates.spillThreshold = ctxt.flags.spillThreshold
+			if err := ctxt.collectWatchedPath(wp); err != nil {
+				return err
+			}
+			ctxt.watched = append(ctxt.watched, wp)
+		}
+	}
+	return ctxt.mergeWatchedCandidates()
+}
+
+// collectWatchedPath (re-)analyzes the wp path.
+// Results of the previous analysis are discarded.
+func (ctxt *context) collectWatchedPath(wp *watchedPath) error {
+	name := wp.path.path
+	if wp.config != (buildConfig{}) {
+		name += " " + wp.config.String()
+	}
+	ctxt.infoPrintf("check %q", name)
+
+	if wp.counts != nil {
+		wp.counts.subtract()
+	}
+	for _, filename := range wp.files {
+		delete(ctxt.seenFiles, filename)
+	}
+	wp.candidates.Reset()
+	wp.files = wp.files[:0]
+
+	ctxt.onLoad = func(pkgs []*packages.Package) {
+		wp.dirs = wp.dirs[:0]
+		dirs := make(map[string]bool)
+		for _, pkg := range pkgs {
+			if strings.HasSuffix(pkg.PkgPath, ".test") {
+				continue // Generated test main package
+			}
+			for _, filename := range pkg.GoFiles {
+				dirs[filepath.Dir(filename)] = true
+			}
+		}
+		for dir := range dirs {
+			wp.dirs = append(wp.dirs, dir)
+		}
+		sort.Strings(wp.dirs)
+	}
+	ctxt.onSeen = func(key string) {
+		wp.files = append(wp.files, key)
+	}
+	defer func() {
+		ctxt.onLoad = nil
+		ctxt.onSeen = nil
+	}()
+
+	// Collect the candidates into the wp own list.
+	allCandidates := ctxt.candidates
+	ctxt.candidates = wp.candidates
+	counts := ctxt.variantCounts()
+	err := ctxt.collectCachedPathCandidates(wp.path, wp.config)
+	wp.counts = counts.diff()
+	wp.candidates = ctxt.candidates
+	ctxt.candidates = allCandidates
+
+	// Record the stamp even on failure, so the path is
+	// re-analyzed only after the next change.
+	wp.stamp = dirsStamp(wp.dirs)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// mergeWatchedCandidates rebuilds the candidates list from the watched paths.
+func (ctxt *context) mergeWatchedCandidates() error {
+	ctxt.candidates.Reset()
+	for _, wp := range ctxt.watched {
+		err := wp.candidates.Visit(func(c candidate) {
+			ctxt.candidates.Append(c)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return ctxt.checkPools()
+}
+
+// watch re-analyzes the changed paths and reprints the warnings
+// until the program is interrupted.
+// Does nothing if the -watch flag is not set.
+func (ctxt *context) watch() error {
+	if !ctxt.flags.watch {
+		return nil
+	}
+
+	for {
+		time.Sleep(watchInterval)
+
+		changed := 0
+		for _, wp := range ctxt.watched {
+			if dirsStamp(wp.dirs) == wp.stamp {
+				continue
+			}
+			changed++
+			if err := ctxt.collectWatchedPath(wp); err != nil {
+				// Most likely, the code is being edited right now.
+				// Report the error and wait for the next change.
+				fmt.Fprintf(os.Stderr, "watch: %v\n", err)
+			}
+		}
+		if changed == 0 {
+			continue
+		}
+
+		if err := ctxt.mergeWatchedCandidates(); err != nil {
+			return err
+		}
+		if err := ctxt.assignSuggestions(); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "--- %s: %d %s changed\n",
+			time.Now().Format(time.TimeOnly), changed, plural(changed, "package"))
+		if err := ctxt.printWarnings(); err != nil {
+			return err
+		}
+	}
+}
+
+// dirsStamp returns a fingerprint of the Go files inside dirs.
+// Any Go file addition, removal or modification changes the fingerprint.
+func dirsStamp(dirs []string) string {
+	var sb strings.Builder
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			fmt.Fprintf(&sb, "%s: %v\n", dir, err)
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasSuffix(e.Name(), ".go") {
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&sb, "%s/%s %d %d\n", dir, e.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCollectWatchedPath(t *testing.T) {
+	var ctxt context
+	ctxt.flags.watch = true
+	ctxt.paths = []targetPath{{path: "./testdata/tests_separate"}}
+	_ = ctxt.initCheckers()
+	if err := ctxt.collectAllCandidates(); err != nil {
+		t.Fatalf("collect candidates: %v", err)
+	}
+	before := ctxt.variantCounts()
+	total := ctxt.candidates.Len()
+
+	// Re-analyzing an unchanged path should give the same results.
+	for i := 0; i < 2; i++ {
+		if err := ctxt.collectWatchedPath(ctxt.watched[0]); err != nil {
+			t.Fatalf("recollect: %v", err)
+		}
+	}
+	if err := ctxt.mergeWatchedCandidates(); err != nil {
+		t.Fatalf("merge candidates: %v", err)
+	}
+	if ctxt.candidates.Len() != total {
+		t.Errorf("have %d candidates, want %d", ctxt.candidates.Len(), total)
+	}
+	for v, want := range before {
+		have := append([]int{v.count}, v.poolCounts...)
+		if len(have) != len(want) || have[0] != want[0] {
+			t.Errorf("%s: have %v counts, want %v", v.warning, have, want)
+		}
+	}
+	if len(ctxt.watched[0].dirs) != 1 {
+		t.Errorf("have %v watched dirs, want 1 dir", ctxt.watched[0].dirs)
+	}
+}